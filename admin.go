@@ -0,0 +1,241 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	gossh "golang.org/x/crypto/ssh"
+)
+
+const adminUsage = `usage:
+  sshwitter admin users list [-unverified]
+  sshwitter admin users verify [-all] [NAME...]
+  sshwitter admin users promote [-revoke] NAME
+  sshwitter admin users suspend [-lift] NAME
+  sshwitter admin users set-key NAME KEYFILE|-
+  sshwitter admin posts delete ID...`
+
+// adminCommand runs operational tasks against the database,
+// without starting the server.
+func adminCommand(args []string) int {
+	if len(args) < 2 {
+		fmt.Fprintln(os.Stderr, adminUsage)
+		return 2
+	}
+	var run func(ctx context.Context, db *sql.DB, args []string) int
+	switch args[0] + " " + args[1] {
+	case "users list":
+		run = listUsersCommand
+	case "users verify":
+		run = verifyUsersCommand
+	case "users promote":
+		run = promoteUserCommand
+	case "users suspend":
+		run = suspendUserCommand
+	case "users set-key":
+		run = setKeyCommand
+	case "posts delete":
+		run = deletePostsCommand
+	default:
+		fmt.Fprintf(os.Stderr, "unknown command %q\n", strings.Join(args[:2], " "))
+		fmt.Fprintln(os.Stderr, adminUsage)
+		return 2
+	}
+
+	db, err := connectDB()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	defer db.Close()
+	CreateSchema(db)
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	return run(ctx, db, args[2:])
+}
+
+func listUsersCommand(ctx context.Context, db *sql.DB, args []string) int {
+	flags := flag.NewFlagSet("users list", flag.ContinueOnError)
+	unverified := flags.Bool("unverified", false, "only list users waiting for verification")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	find := GetAllUsers
+	if *unverified {
+		find = GetUnverifiedUsers
+	}
+	users, err := find(ctx, db)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, ErrorMessage(err, err.Error()))
+		return 1
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tUSERNAME\tEMAIL\tVERIFIED\tADMIN\tSUSPENDED\tCREATED")
+	for _, user := range users {
+		if user.username == deletedUsername {
+			continue
+		}
+		fmt.Fprintf(w, "%d\t%s\t%s\t%t\t%t\t%t\t%s\n", user.id, user.username, user.email,
+			user.verified, user.administrator, user.suspended, user.createdAt.Format("2006-01-02"))
+	}
+	w.Flush()
+	return 0
+}
+
+func verifyUsersCommand(ctx context.Context, db *sql.DB, args []string) int {
+	flags := flag.NewFlagSet("users verify", flag.ContinueOnError)
+	all := flags.Bool("all", false, "verify every user waiting for verification")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	var users []SavedUser
+	if *all {
+		var err error
+		users, err = GetUnverifiedUsers(ctx, db)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, ErrorMessage(err, err.Error()))
+			return 1
+		}
+	} else if flags.NArg() == 0 {
+		fmt.Fprintln(os.Stderr, "usage: sshwitter admin users verify [-all] [NAME...]")
+		return 2
+	}
+	for _, username := range flags.Args() {
+		user, err := GetUserByUsername(ctx, db, username)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s: %s\n", username, ErrorMessage(err, err.Error()))
+			return 1
+		}
+		users = append(users, user)
+	}
+	failed := 0
+	for _, user := range users {
+		if err := AcceptUser(ctx, db, user); err != nil {
+			fmt.Fprintf(os.Stderr, "%s: %s\n", user.username, ErrorMessage(err, err.Error()))
+			failed += 1
+			continue
+		}
+		fmt.Printf("verified %s\n", user.username)
+	}
+	if failed > 0 {
+		return 1
+	}
+	return 0
+}
+
+// userCommand parses a NAME argument and the given flags, then
+// runs update on that user.
+func userCommand(ctx context.Context, db *sql.DB, flags *flag.FlagSet, args []string, update func(SavedUser) (string, error)) int {
+	flags.SetOutput(io.Discard)
+	if err := flags.Parse(args); err != nil || flags.NArg() != 1 {
+		fmt.Fprintln(os.Stderr, adminUsage)
+		return 2
+	}
+	user, err := GetUserByUsername(ctx, db, flags.Arg(0))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s: %s\n", flags.Arg(0), ErrorMessage(err, err.Error()))
+		return 1
+	}
+	done, err := update(user)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s: %s\n", user.username, ErrorMessage(err, err.Error()))
+		return 1
+	}
+	fmt.Printf("%s %s\n", done, user.username)
+	return 0
+}
+
+func promoteUserCommand(ctx context.Context, db *sql.DB, args []string) int {
+	flags := flag.NewFlagSet("users promote", flag.ContinueOnError)
+	revoke := flags.Bool("revoke", false, "remove administrator rights instead")
+	return userCommand(ctx, db, flags, args, func(user SavedUser) (string, error) {
+		if *revoke {
+			return "revoked administrator rights of", SetAdministrator(ctx, db, user, false)
+		}
+		return "promoted", SetAdministrator(ctx, db, user, true)
+	})
+}
+
+func suspendUserCommand(ctx context.Context, db *sql.DB, args []string) int {
+	flags := flag.NewFlagSet("users suspend", flag.ContinueOnError)
+	lift := flags.Bool("lift", false, "lift the suspension instead")
+	return userCommand(ctx, db, flags, args, func(user SavedUser) (string, error) {
+		if *lift {
+			return "lifted the suspension of", SetSuspended(ctx, db, user, false)
+		}
+		return "suspended", SetSuspended(ctx, db, user, true)
+	})
+}
+
+// setKeyCommand replaces the key of a user who lost theirs. The
+// key is read as an authorized_keys line from a file or stdin.
+func setKeyCommand(ctx context.Context, db *sql.DB, args []string) int {
+	if len(args) != 2 {
+		fmt.Fprintln(os.Stderr, "usage: sshwitter admin users set-key NAME KEYFILE|-")
+		return 2
+	}
+	var content []byte
+	var err error
+	if args[1] == "-" {
+		content, err = io.ReadAll(os.Stdin)
+	} else {
+		content, err = os.ReadFile(args[1])
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	key, _, _, _, err := gossh.ParseAuthorizedKey(content)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "invalid public key:", err)
+		return 1
+	}
+	flags := flag.NewFlagSet("users set-key", flag.ContinueOnError)
+	return userCommand(ctx, db, flags, args[:1], func(user SavedUser) (string, error) {
+		return "changed the key of", UpdateUserKey(ctx, db, user, ConvertKey(key))
+	})
+}
+
+func deletePostsCommand(ctx context.Context, db *sql.DB, args []string) int {
+	if len(args) == 0 {
+		fmt.Fprintln(os.Stderr, "usage: sshwitter admin posts delete ID...")
+		return 2
+	}
+	ids := make([]int64, len(args))
+	for i, arg := range args {
+		id, err := strconv.ParseInt(arg, 10, 64)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "invalid post id %q\n", arg)
+			return 2
+		}
+		ids[i] = id
+	}
+	failed := 0
+	for _, id := range ids {
+		err := RemovePost(ctx, db, id)
+		if errors.Is(err, ErrNotFound) {
+			fmt.Fprintf(os.Stderr, "post %d not found\n", id)
+			failed += 1
+			continue
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "post %d: %s\n", id, ErrorMessage(err, err.Error()))
+			return 1
+		}
+		fmt.Printf("deleted post %d\n", id)
+	}
+	if failed > 0 {
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,156 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/charmbracelet/log"
+)
+
+type Severity string
+
+const (
+	infoSeverity     Severity = "info"
+	warningSeverity  Severity = "warning"
+	criticalSeverity Severity = "critical"
+)
+
+var severities = []Severity{infoSeverity, warningSeverity, criticalSeverity}
+
+type Announcement struct {
+	id         int64
+	content    string
+	severity   Severity
+	createdAt  time.Time
+	expiresAt  sql.NullTime
+}
+
+func (a Announcement) Expired() bool {
+	return a.expiresAt.Valid && !a.expiresAt.Time.After(time.Now())
+}
+
+func CreateAnnouncementTable(db *sql.DB) {
+	createTableSQL := `
+	CREATE TABLE IF NOT EXISTS announcements (
+		id SERIAL PRIMARY KEY,
+		content VARCHAR(200) NOT NULL,
+		severity VARCHAR(16) NOT NULL,
+		created_by INTEGER REFERENCES users(id) ON DELETE SET NULL,
+		created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+		expires_at TIMESTAMP WITH TIME ZONE
+	);
+	CREATE TABLE IF NOT EXISTS announcement_dismissals (
+		user_id INTEGER REFERENCES users(id) ON DELETE CASCADE,
+		announcement_id INTEGER REFERENCES announcements(id) ON DELETE CASCADE,
+		dismissed_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+		PRIMARY KEY (user_id, announcement_id)
+	);`
+
+	_, err := db.Exec(createTableSQL)
+	if err != nil {
+		log.Fatalf("Failed to create table: %v", err)
+	}
+
+	log.Info("Table 'announcements' created successfully!")
+}
+
+func SaveAnnouncement(ctx context.Context, db *sql.DB, author SavedUser, content string, severity Severity, expiresAt sql.NullTime) (Announcement, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+	log.Info("Saving announcement", "author", author.username, "severity", severity)
+	announcement := Announcement{content: SanitizeLine(content), severity: severity, expiresAt: expiresAt}
+	query := `
+	INSERT INTO announcements (content, severity, created_by, expires_at)
+	VALUES ($1, $2, $3, $4)
+	RETURNING id, created_at`
+	err := db.QueryRowContext(ctx, query, announcement.content, string(severity), author.id, expiresAt).
+		Scan(&announcement.id, &announcement.createdAt)
+	if err != nil {
+		log.Errorf("failed to insert announcement: %v", err)
+		return announcement, fmt.Errorf("failed to insert announcement: %w", mapDbError(err))
+	}
+	return announcement, nil
+}
+
+func DeleteAnnouncement(ctx context.Context, db *sql.DB, id int64) error {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+	log.Info("Deleting announcement", "id", id)
+	result, err := db.ExecContext(ctx, `DELETE FROM announcements WHERE id = $1`, id)
+	if err != nil {
+		log.Errorf("failed to delete announcement: %v", err)
+		return fmt.Errorf("failed to delete announcement: %w", mapDbError(err))
+	}
+	if n, err := result.RowsAffected(); err == nil && n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func DismissAnnouncement(ctx context.Context, db *sql.DB, user SavedUser, id int64) error {
+	if err := checkWritable(); err != nil {
+		return err
+	}
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+	query := `
+	INSERT INTO announcement_dismissals (user_id, announcement_id) VALUES ($1, $2)
+	ON CONFLICT DO NOTHING`
+	_, err := db.ExecContext(ctx, query, user.id, id)
+	if err != nil {
+		log.Errorf("failed to dismiss announcement: %v", err)
+		return fmt.Errorf("failed to dismiss announcement: %w", mapDbError(err))
+	}
+	return nil
+}
+
+// FindAnnouncements lists announcements that haven't expired,
+// newest first.
+func FindAnnouncements(ctx context.Context, db *sql.DB) ([]Announcement, error) {
+	query := `
+	SELECT id, content, severity, created_at, expires_at
+	FROM announcements
+	WHERE expires_at IS NULL OR expires_at > NOW()
+	ORDER BY created_at DESC`
+	return findAnnouncements(ctx, db, query)
+}
+
+// FindActiveAnnouncements lists what user should see at login:
+// announcements that haven't expired nor been dismissed.
+func FindActiveAnnouncements(ctx context.Context, db *sql.DB, user SavedUser) ([]Announcement, error) {
+	query := `
+	SELECT a.id, a.content, a.severity, a.created_at, a.expires_at
+	FROM announcements a
+	LEFT JOIN announcement_dismissals d ON d.announcement_id = a.id AND d.user_id = $1
+	WHERE d.user_id IS NULL
+	AND (a.expires_at IS NULL OR a.expires_at > NOW())
+	ORDER BY a.created_at DESC`
+	return findAnnouncements(ctx, db, query, user.id)
+}
+
+func findAnnouncements(ctx context.Context, db *sql.DB, query string, args ...any) ([]Announcement, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+	rows, err := db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, mapDbError(err)
+	}
+	defer rows.Close()
+
+	var announcements []Announcement
+	for rows.Next() {
+		var a Announcement
+		if err := rows.Scan(&a.id, &a.content, &a.severity, &a.createdAt, &a.expiresAt); err != nil {
+			return nil, mapDbError(err)
+		}
+		announcements = append(announcements, a)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, mapDbError(err)
+	}
+
+	return announcements, nil
+}
@@ -0,0 +1,191 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/charmbracelet/log"
+)
+
+// maxShownAnnouncements bounds the lines taken above the tabs.
+const maxShownAnnouncements = 3
+
+type AnnouncementsLoadedMsg struct {
+	announcements []Announcement
+}
+
+// AnnouncementMsg is broadcast to every session when an
+// announcement is published.
+type AnnouncementMsg struct {
+	announcement Announcement
+}
+
+// AnnouncementRemovedMsg hides an announcement, because it was
+// deleted, dismissed or it expired.
+type AnnouncementRemovedMsg struct {
+	id int64
+}
+
+func loadAnnouncements(ctx context.Context, db *sql.DB, user SavedUser) tea.Cmd {
+	return func() tea.Msg {
+		if ctx.Err() != nil {
+			return nil
+		}
+		announcements, err := FindActiveAnnouncements(ctx, db, user)
+		if err != nil {
+			log.Error("Could not load announcements", "error", err)
+			return nil
+		}
+		return AnnouncementsLoadedMsg{announcements: announcements}
+	}
+}
+
+func dismissAnnouncement(ctx context.Context, db *sql.DB, user SavedUser, id int64) tea.Cmd {
+	return func() tea.Msg {
+		if err := DismissAnnouncement(context.WithoutCancel(ctx), db, user, id); err != nil {
+			log.Error("Could not dismiss announcement", "error", err)
+		}
+		return nil
+	}
+}
+
+// expireAnnouncement hides a once it expires.
+func expireAnnouncement(a Announcement) tea.Cmd {
+	if !a.expiresAt.Valid {
+		return nil
+	}
+	return tea.Tick(time.Until(a.expiresAt.Time), func(time.Time) tea.Msg {
+		return AnnouncementRemovedMsg{id: a.id}
+	})
+}
+
+// ParseExpiry reads how long an announcement is shown, like
+// "90m" or "3d". An empty value never expires.
+func ParseExpiry(s string) (sql.NullTime, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return sql.NullTime{}, nil
+	}
+	var duration time.Duration
+	var err error
+	if days, found := strings.CutSuffix(s, "d"); found {
+		var n int
+		n, err = strconv.Atoi(days)
+		duration = time.Duration(n) * 24 * time.Hour
+	} else {
+		duration, err = time.ParseDuration(s)
+	}
+	if err != nil || duration <= 0 {
+		return sql.NullTime{}, errors.New("Use a duration like 30m, 2h or 3d")
+	}
+	return sql.NullTime{Time: time.Now().Add(duration), Valid: true}, nil
+}
+
+func expiryValidator(s string) error {
+	_, err := ParseExpiry(s)
+	return err
+}
+
+func announcementValidator(s string) error {
+	if strings.TrimFunc(s, unicode.IsSpace) == "" {
+		return errors.New("Announcement cannot be empty")
+	}
+	return nil
+}
+
+// AnnouncementBar shows announcements above the tab row, one
+// line each, newest first.
+type AnnouncementBar struct {
+	announcements []Announcement
+	styles        map[Severity]lipgloss.Style
+}
+
+func CreateAnnouncementBar(renderer *lipgloss.Renderer) AnnouncementBar {
+	base := renderer.NewStyle().Padding(0, 1)
+	return AnnouncementBar{
+		styles: map[Severity]lipgloss.Style{
+			infoSeverity: base.
+				Foreground(lipgloss.Color("15")).
+				Background(lipgloss.Color("#1da1f2")),
+			warningSeverity: base.
+				Foreground(lipgloss.Color("0")).
+				Background(lipgloss.Color("11")),
+			criticalSeverity: base.
+				Foreground(lipgloss.Color("15")).
+				Background(lipgloss.Color("#cc0000")).
+				Bold(true),
+		},
+	}
+}
+
+// Set replaces the announcements and returns the commands
+// hiding them once they expire.
+func (b *AnnouncementBar) Set(announcements []Announcement) tea.Cmd {
+	b.announcements = nil
+	cmds := make([]tea.Cmd, 0, len(announcements))
+	for i := len(announcements) - 1; i >= 0; i-- {
+		cmds = append(cmds, b.Add(announcements[i]))
+	}
+	return tea.Batch(cmds...)
+}
+
+func (b *AnnouncementBar) Add(a Announcement) tea.Cmd {
+	if a.Expired() {
+		return nil
+	}
+	for _, existing := range b.announcements {
+		if existing.id == a.id {
+			return nil
+		}
+	}
+	b.announcements = append([]Announcement{a}, b.announcements...)
+	return expireAnnouncement(a)
+}
+
+func (b *AnnouncementBar) Remove(id int64) {
+	for i, a := range b.announcements {
+		if a.id == id {
+			b.announcements = append(b.announcements[:i], b.announcements[i+1:]...)
+			return
+		}
+	}
+}
+
+// First is the announcement dismissed by alt+d.
+func (b AnnouncementBar) First() (Announcement, bool) {
+	if len(b.announcements) == 0 {
+		return Announcement{}, false
+	}
+	return b.announcements[0], true
+}
+
+func (b AnnouncementBar) Height() int {
+	return min(len(b.announcements), maxShownAnnouncements)
+}
+
+func (b AnnouncementBar) View(width int) string {
+	lines := make([]string, 0, b.Height())
+	for i, a := range b.announcements[:b.Height()] {
+		text := SanitizeLine(a.content)
+		if i == 0 {
+			text += "  (alt+d: dismiss)"
+		}
+		if i == maxShownAnnouncements - 1 && len(b.announcements) > maxShownAnnouncements {
+			text += fmt.Sprintf(" (+%d more)", len(b.announcements) - maxShownAnnouncements)
+		}
+		style, ok := b.styles[a.severity]
+		if !ok {
+			style = b.styles[infoSeverity]
+		}
+		lines = append(lines, style.MaxWidth(max(width, 20)).Render(text))
+	}
+	return lipgloss.JoinVertical(lipgloss.Left, lines...)
+}
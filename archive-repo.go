@@ -0,0 +1,349 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/charmbracelet/log"
+)
+
+const archiveVersion = 1
+
+// Archive is everything an account owns, as written by the
+// export command. Posts of other users are referenced by author
+// and creation time, which stay the same across instances.
+type Archive struct {
+	Version    int              `json:"version"`
+	ExportedAt time.Time        `json:"exported_at"`
+	Profile    ArchiveProfile   `json:"profile"`
+	Posts      []ArchivePost    `json:"posts"`
+	Likes      []ArchiveLike    `json:"likes"`
+	Follows    []string         `json:"follows"`
+	Followers  []string         `json:"followers"`
+}
+
+type ArchiveProfile struct {
+	Username       string    `json:"username"`
+	Email          string    `json:"email"`
+	Key            string    `json:"key"`
+	Description    string    `json:"description,omitempty"`
+	Location       string    `json:"location,omitempty"`
+	BirthDate      time.Time `json:"birth_date"`
+	CreatedAt      time.Time `json:"created_at"`
+	Verified       bool      `json:"verified"`
+	ExpandWarnings bool      `json:"expand_warnings"`
+}
+
+type ArchivePostRef struct {
+	Author    string    `json:"author"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type ArchivePost struct {
+	Content        string          `json:"content"`
+	ContentWarning string          `json:"content_warning,omitempty"`
+	CreatedAt      time.Time       `json:"created_at"`
+	Likes          int             `json:"likes"`
+	Replies        int             `json:"replies"`
+	ReplyTo        *ArchivePostRef `json:"reply_to,omitempty"`
+}
+
+type ArchiveLike struct {
+	Post    ArchivePostRef `json:"post"`
+	LikedAt time.Time      `json:"liked_at"`
+}
+
+func ExportArchive(ctx context.Context, db *sql.DB, user SavedUser) (Archive, error) {
+	log.Info("Exporting account", "user", user.username)
+	archive := Archive{
+		Version: archiveVersion,
+		ExportedAt: time.Now(),
+		Profile: ArchiveProfile{
+			Username: user.username,
+			Email: user.email,
+			Key: user.key,
+			Description: user.description.String,
+			Location: user.location.String,
+			BirthDate: user.birthDate,
+			CreatedAt: user.createdAt,
+			Verified: user.verified,
+			ExpandWarnings: user.expandWarnings,
+		},
+		Posts: []ArchivePost{},
+		Likes: []ArchiveLike{},
+	}
+
+	var err error
+	if archive.Posts, err = exportPosts(ctx, db, user); err != nil {
+		return archive, err
+	}
+	if archive.Likes, err = exportLikes(ctx, db, user); err != nil {
+		return archive, err
+	}
+	followsQuery := `
+	SELECT u.username FROM follows f
+	INNER JOIN users u ON u.id = f.followed_id
+	WHERE f.user_id = $1
+	ORDER BY f.followed_at`
+	if archive.Follows, err = exportUsernames(ctx, db, followsQuery, user); err != nil {
+		return archive, err
+	}
+	followersQuery := `
+	SELECT u.username FROM follows f
+	INNER JOIN users u ON u.id = f.user_id
+	WHERE f.followed_id = $1
+	ORDER BY f.followed_at`
+	if archive.Followers, err = exportUsernames(ctx, db, followersQuery, user); err != nil {
+		return archive, err
+	}
+	return archive, nil
+}
+
+func exportPosts(ctx context.Context, db *sql.DB, user SavedUser) ([]ArchivePost, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+	query := `
+	SELECT p.content, COALESCE(p.content_warning, ''), p.created_at, p.likes, p.replies,
+	       pu.username, parent.created_at
+	FROM posts p
+	LEFT JOIN posts parent ON parent.id = p.parent_id
+	LEFT JOIN users pu ON pu.id = parent.user_id
+	WHERE p.user_id = $1
+	ORDER BY p.created_at`
+	rows, err := db.QueryContext(ctx, query, user.id)
+	if err != nil {
+		log.Errorf("failed to export posts: %v", err)
+		return nil, fmt.Errorf("failed to export posts: %w", mapDbError(err))
+	}
+	defer rows.Close()
+
+	posts := []ArchivePost{}
+	for rows.Next() {
+		var post ArchivePost
+		var parentAuthor sql.NullString
+		var parentCreatedAt sql.NullTime
+		if err := rows.Scan(&post.Content, &post.ContentWarning, &post.CreatedAt, &post.Likes, &post.Replies, &parentAuthor, &parentCreatedAt); err != nil {
+			return nil, mapDbError(err)
+		}
+		if parentAuthor.Valid && parentCreatedAt.Valid {
+			post.ReplyTo = &ArchivePostRef{Author: parentAuthor.String, CreatedAt: parentCreatedAt.Time}
+		}
+		posts = append(posts, post)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, mapDbError(err)
+	}
+
+	return posts, nil
+}
+
+func exportLikes(ctx context.Context, db *sql.DB, user SavedUser) ([]ArchiveLike, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+	query := `
+	SELECT u.username, p.created_at, l.liked_at
+	FROM likes l
+	INNER JOIN posts p ON p.id = l.post_id
+	INNER JOIN users u ON u.id = p.user_id
+	WHERE l.user_id = $1
+	ORDER BY l.liked_at`
+	rows, err := db.QueryContext(ctx, query, user.id)
+	if err != nil {
+		log.Errorf("failed to export likes: %v", err)
+		return nil, fmt.Errorf("failed to export likes: %w", mapDbError(err))
+	}
+	defer rows.Close()
+
+	likes := []ArchiveLike{}
+	for rows.Next() {
+		var like ArchiveLike
+		if err := rows.Scan(&like.Post.Author, &like.Post.CreatedAt, &like.LikedAt); err != nil {
+			return nil, mapDbError(err)
+		}
+		likes = append(likes, like)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, mapDbError(err)
+	}
+
+	return likes, nil
+}
+
+func exportUsernames(ctx context.Context, db *sql.DB, query string, user SavedUser) ([]string, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+	rows, err := db.QueryContext(ctx, query, user.id)
+	if err != nil {
+		log.Errorf("failed to export follows: %v", err)
+		return nil, fmt.Errorf("failed to export follows: %w", mapDbError(err))
+	}
+	defer rows.Close()
+
+	usernames := []string{}
+	for rows.Next() {
+		var username string
+		if err := rows.Scan(&username); err != nil {
+			return nil, mapDbError(err)
+		}
+		usernames = append(usernames, username)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, mapDbError(err)
+	}
+
+	return usernames, nil
+}
+
+type ImportReport struct {
+	skipped  int
+	detached int
+}
+
+// ImportArchive restores an exported account in one transaction.
+// Likes and follows pointing at posts or users missing from this
+// instance are skipped; replies to missing posts are imported as
+// top-level posts. Both are counted in the report. The account is
+// unverified unless keepVerified trusts the archive's flag.
+// Counters aren't touched; run Reconcile afterwards.
+func ImportArchive(ctx context.Context, db *sql.DB, archive Archive, keepVerified bool) (ImportReport, error) {
+	var report ImportReport
+	if archive.Version != archiveVersion {
+		return report, fmt.Errorf("unsupported archive version %d", archive.Version)
+	}
+	log.Info("Importing account", "user", archive.Profile.Username)
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		log.Errorf("failed to start transaction: %v", err)
+		return report, fmt.Errorf("failed to start transaction: %w", mapDbError(err))
+	}
+	defer tx.Rollback()
+
+	profile := archive.Profile
+	var userId int64
+	query := `
+	INSERT INTO users (key, username, email, verified, administrator, birth_date, created_at, description, location, expand_warnings)
+	VALUES ($1, $2, $3, $4, false, $5, $6, NULLIF($7, ''), NULLIF($8, ''), $9)
+	RETURNING id`
+	err = queryRowTx(ctx, tx, query, []any{&userId},
+		profile.Key, SanitizeLine(profile.Username), SanitizeLine(profile.Email), keepVerified && profile.Verified,
+		profile.BirthDate, profile.CreatedAt, SanitizeLine(profile.Description), SanitizeLine(profile.Location),
+		profile.ExpandWarnings)
+	if err != nil {
+		log.Errorf("failed to import user: %v", err)
+		return report, fmt.Errorf("failed to import user: %w", mapDbError(err))
+	}
+
+	posts := append([]ArchivePost{}, archive.Posts...)
+	sort.SliceStable(posts, func(i, j int) bool {
+		return posts[i].CreatedAt.Before(posts[j].CreatedAt)
+	})
+	for _, post := range posts {
+		var parentId sql.NullInt64
+		if post.ReplyTo != nil {
+			parentId, err = findPostRef(ctx, tx, *post.ReplyTo)
+			if err != nil {
+				return report, err
+			}
+			if !parentId.Valid {
+				report.detached += 1
+			}
+		}
+		query := `
+		INSERT INTO posts (content, content_warning, user_id, created_at, parent_id)
+		VALUES ($1, NULLIF($2, ''), $3, $4, $5)`
+		_, err = execTx(ctx, tx, query, Sanitize(post.Content), Sanitize(post.ContentWarning), userId, post.CreatedAt, parentId)
+		if err != nil {
+			log.Errorf("failed to import post: %v", err)
+			return report, fmt.Errorf("failed to import post: %w", mapDbError(err))
+		}
+	}
+
+	for _, like := range archive.Likes {
+		postId, err := findPostRef(ctx, tx, like.Post)
+		if err != nil {
+			return report, err
+		}
+		if !postId.Valid {
+			report.skipped += 1
+			continue
+		}
+		query := `
+		INSERT INTO likes (user_id, post_id, liked_at) VALUES ($1, $2, $3)
+		ON CONFLICT DO NOTHING`
+		if _, err = execTx(ctx, tx, query, userId, postId, like.LikedAt); err != nil {
+			log.Errorf("failed to import like: %v", err)
+			return report, fmt.Errorf("failed to import like: %w", mapDbError(err))
+		}
+	}
+
+	follows := []struct {
+		usernames []string
+		query     string
+	}{
+		{archive.Follows, `
+		INSERT INTO follows (user_id, followed_id)
+		SELECT $1, id FROM users WHERE username = $2
+		ON CONFLICT DO NOTHING`},
+		{archive.Followers, `
+		INSERT INTO follows (user_id, followed_id)
+		SELECT id, $1 FROM users WHERE username = $2
+		ON CONFLICT DO NOTHING`},
+	}
+	for _, f := range follows {
+		for _, username := range f.usernames {
+			result, err := execTx(ctx, tx, f.query, userId, username)
+			if err != nil {
+				log.Errorf("failed to import follow: %v", err)
+				return report, fmt.Errorf("failed to import follow: %w", mapDbError(err))
+			}
+			if n, err := result.RowsAffected(); err == nil && n == 0 {
+				report.skipped += 1
+			}
+		}
+	}
+
+	if err = tx.Commit(); err != nil {
+		log.Errorf("failed to commit import: %v", err)
+		return report, fmt.Errorf("failed to commit import: %w", mapDbError(err))
+	}
+	log.Info("Imported account", "user", profile.Username, "skipped", report.skipped, "detached replies", report.detached)
+	return report, nil
+}
+
+func findPostRef(ctx context.Context, tx *sql.Tx, ref ArchivePostRef) (sql.NullInt64, error) {
+	var id sql.NullInt64
+	query := `
+	SELECT p.id FROM posts p
+	INNER JOIN users u ON u.id = p.user_id
+	WHERE u.username = $1 AND p.created_at = $2
+	LIMIT 1`
+	err := queryRowTx(ctx, tx, query, []any{&id}, ref.Author, ref.CreatedAt)
+	if err == sql.ErrNoRows {
+		return sql.NullInt64{}, nil
+	}
+	if err != nil {
+		log.Errorf("failed to find post: %v", err)
+		return id, fmt.Errorf("failed to find post: %w", mapDbError(err))
+	}
+	return id, nil
+}
+
+// execTx and queryRowTx apply the query timeout to a single
+// statement of a long transaction.
+func execTx(ctx context.Context, tx *sql.Tx, query string, args ...any) (sql.Result, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+	return tx.ExecContext(ctx, query, args...)
+}
+
+func queryRowTx(ctx context.Context, tx *sql.Tx, query string, dest []any, args ...any) error {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+	return tx.QueryRowContext(ctx, query, args...).Scan(dest...)
+}
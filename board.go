@@ -1,7 +1,9 @@
 package main
 
 import (
+	"context"
 	"database/sql"
+	"time"
 
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/charmbracelet/lipgloss"
@@ -12,20 +14,24 @@ type Tab struct {
     Name      string
 }
 
-func getBoardModel(renderer *lipgloss.Renderer, db *sql.DB, user SavedUser) (BoardModel) {
+func getBoardModel(ctx context.Context, renderer *lipgloss.Renderer, db *sql.DB, user SavedUser) (BoardModel) {
 	txtStyle := renderer.NewStyle().Foreground(lipgloss.Color("10"))
 	quitStyle := renderer.NewStyle().Foreground(lipgloss.Color("8"))
 	usernameStyle := renderer.NewStyle().Foreground(lipgloss.Color("5"))
+	noticeStyle := renderer.NewStyle().
+		Foreground(lipgloss.Color("0")).
+		Background(lipgloss.Color("11")).
+		Padding(0, 1)
 
 	tabs := []Tab{ }
-	tabs = append(tabs, getFeedView(renderer, db, user, FindAllPosts, "Feed"))
-	tabs = append(tabs, getFeedView(renderer, db, user, FindFollowedPosts, "Follows"))
-	tabs = append(tabs, getFeedView(renderer, db, user, FindLikedPosts, "Likes"))
-	tabs = append(tabs, getProfileView(renderer, db, user.username, user))
+	tabs = append(tabs, getFeedView(ctx, renderer, db, user, FindAllPosts, "Feed"))
+	tabs = append(tabs, getFeedView(ctx, renderer, db, user, FindFollowsFeed, "Follows"))
+	tabs = append(tabs, getFeedView(ctx, renderer, db, user, FindLikedPosts, "Likes"))
+	tabs = append(tabs, getProfileView(ctx, renderer, db, user.username, user))
 
 
 	if (user.administrator) {
-		tabs = append(tabs, getModeratorTab(renderer, db))
+		tabs = append(tabs, getModeratorTab(ctx, renderer, db, user))
 	}
 
 	activeTabBorder := lipgloss.Border{
@@ -57,12 +63,16 @@ func getBoardModel(renderer *lipgloss.Renderer, db *sql.DB, user SavedUser) (Boa
 		txtStyle: txtStyle, 
 		quitStyle: quitStyle,
 		userStyle: usernameStyle,
+		noticeStyle: noticeStyle,
+		readOnly: ReadOnly(),
+		announcements: CreateAnnouncementBar(renderer),
 		currentTab: 0,
 		tabs: tabs,
 		tabStyle: tabStyle,
 		aTabStyle: activeTabStyle,
 		renderer: renderer,
 		db: db,
+		ctx: ctx,
 	}
 }
 
@@ -72,19 +82,127 @@ type BoardModel struct {
 	txtStyle   lipgloss.Style
 	quitStyle  lipgloss.Style
 	userStyle  lipgloss.Style
+	noticeStyle lipgloss.Style
 	tabStyle   lipgloss.Style
 	aTabStyle  lipgloss.Style
 	currentTab int
 	tabs       []Tab
 	renderer   *lipgloss.Renderer
 	db         *sql.DB
+	ctx        context.Context
 	lastResize tea.WindowSizeMsg
+	notice     string
+	readOnly   bool
+	announcements AnnouncementBar
+}
+
+// Closer is implemented by tabs that need to stop their
+// pending commands once they are closed.
+type Closer interface {
+	Close()
+}
+
+// Titled is implemented by tabs whose label is only known
+// after their data is loaded.
+type Titled interface {
+	Title() string
 }
 
 func (m BoardModel) Init() tea.Cmd {
+	var cmds []tea.Cmd = make([]tea.Cmd, len(m.tabs))
+	for i, tab := range m.tabs {
+		cmds[i] = tab.Init()
+	}
+	cmds = append(cmds, loadDraft(m.ctx, m.db, m.user), loadAnnouncements(m.ctx, m.db, m.user))
+	return tea.Batch(cmds...)
+}
+
+// tabSize is the window size left to tabs below the notices
+// and the announcements.
+func (m BoardModel) tabSize() tea.WindowSizeMsg {
+	size := m.lastResize
+	if m.notice != "" {
+		size.Height -= 1
+	}
+	if m.readOnly {
+		size.Height -= 1
+	}
+	size.Height = max(size.Height - m.announcements.Height(), 0)
+	return size
+}
+
+func (m *BoardModel) resizeTabs() tea.Cmd {
+	var cmds []tea.Cmd = make([]tea.Cmd, len(m.tabs))
+	for i := range m.tabs {
+		m.tabs[i].Model, cmds[i] = m.tabs[i].Model.Update(m.tabSize())
+	}
+	return tea.Batch(cmds...)
+}
+
+// draft finds unpublished text, looking at the current tab
+// first.
+func (m BoardModel) draft() (Draft, bool) {
+	if len(m.tabs) > 0 {
+		if drafter, ok := m.tabs[m.currentTab].Model.(Drafter); ok {
+			if draft, found := drafter.Draft(); found {
+				return draft, true
+			}
+		}
+	}
+	for _, tab := range m.tabs {
+		if drafter, ok := tab.Model.(Drafter); ok {
+			if draft, found := drafter.Draft(); found {
+				return draft, true
+			}
+		}
+	}
+	return Draft{}, false
+}
+
+func (m *BoardModel) restoreDraft(draft Draft) tea.Cmd {
+	if draft.parentId.Valid {
+		tab := getPostView(m.ctx, m.renderer, m.db, draft.parentId.Int64, m.user)
+		var cmd tea.Cmd
+		tab.Model, cmd = tab.Model.(PostViewModel).OpenDraft(draft)
+		m.currentTab = len(m.tabs)
+		return tea.Batch(cmd, m.addTab(tab))
+	}
+	for i, tab := range m.tabs {
+		if feed, ok := tab.Model.(FeedModel); ok {
+			var cmd tea.Cmd
+			m.tabs[i].Model, cmd = feed.OpenDraft(draft)
+			m.currentTab = i
+			return cmd
+		}
+	}
+	for i, tab := range m.tabs {
+		if profile, ok := tab.Model.(ProfileViewModel); ok {
+			var cmd tea.Cmd
+			m.tabs[i].Model, cmd = profile.OpenDraft(draft)
+			m.currentTab = i
+			return cmd
+		}
+	}
 	return nil
 }
 
+func (m *BoardModel) addTab(tab Tab) tea.Cmd {
+	var cmd tea.Cmd
+	tab.Model, cmd = tab.Model.Update(m.tabSize())
+	m.tabs = append(m.tabs, tab)
+	return tea.Batch(cmd, tab.Init())
+}
+
+func (m *BoardModel) removeTab(i int) {
+	if closer, ok := m.tabs[i].Model.(Closer); ok {
+		closer.Close()
+	}
+	m.tabs = append(m.tabs[:i], m.tabs[i+1:]...)
+	if i == len(m.tabs) && i != 0 {
+		m.currentTab = m.currentTab - 1
+	}
+}
+
 func (m BoardModel) GetTab(index int) int {
 	return min(index, len(m.tabs)-1)
 }
@@ -120,23 +238,55 @@ func (m BoardModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			return m, tabMove(right)
 		case "alt+e":
 			return m, editProfile
+		case "alt+d":
+			announcement, ok := m.announcements.First()
+			if !ok || ReadOnly() {
+				return m, nil
+			}
+			m.announcements.Remove(announcement.id)
+			cmd = tea.Batch(m.resizeTabs(), dismissAnnouncement(m.ctx, m.db, m.user, announcement.id))
+			return m, cmd
 		}
 	case tea.WindowSizeMsg:
 		m.lastResize = msg
-		var cmds []tea.Cmd = make([]tea.Cmd, len(m.tabs))
-		for i := range m.tabs {
-			m.tabs[i].Model, cmds[i] = m.tabs[i].Model.Update(msg)
+		cmd = m.resizeTabs()
+		return m, cmd
+	case ShutdownNoticeMsg:
+		if m.notice == "" {
+			cmd = m.resizeTabs()
 		}
-		return m, tea.Batch(cmds...)
+		m.notice = msg.String()
+		if time.Until(msg.at) > 0 {
+			cmd = tea.Batch(cmd, msg.tick())
+		}
+		return m, cmd
+	case ReadOnlyMsg:
+		m.readOnly = msg.enabled
+		cmd = m.resizeTabs()
+		return m, cmd
+	case DrainMsg:
+		draft, found := m.draft()
+		return m, saveDraftAndQuit(m.db, m.user, draft, found)
+	case DraftLoadedMsg:
+		cmd = m.restoreDraft(msg.draft)
+		return m, cmd
+	case AnnouncementsLoadedMsg:
+		cmd = m.announcements.Set(msg.announcements)
+		cmd = tea.Batch(cmd, m.resizeTabs())
+		return m, cmd
+	case AnnouncementMsg:
+		cmd = m.announcements.Add(msg.announcement)
+		cmd = tea.Batch(cmd, m.resizeTabs())
+		return m, cmd
+	case AnnouncementRemovedMsg:
+		m.announcements.Remove(msg.id)
+		cmd = m.resizeTabs()
+		return m, cmd
 	case CloseTabMsg:
 		if len(m.tabs) == 0 {
 			return m, nil
 		}
-		i := msg.page
-		m.tabs = append(m.tabs[:i], m.tabs[i+1:]...)
-		if i == len(m.tabs) && i != 0 {
-			m.currentTab = m.currentTab - 1
-		}
+		m.removeTab(msg.page)
 		return m, nil
 	case OpenFeedMsg:
 		for _, tab  := range m.tabs {
@@ -144,11 +294,7 @@ func (m BoardModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 				return m, nil
 			}
 		}
-		m.tabs = append(
-			m.tabs, 
-			getFeedView(m.renderer, m.db, m.user, msg.find, msg.name),
-		)
-		m.tabs[len(m.tabs)-1].Model, cmd = m.tabs[len(m.tabs)-1].Model.Update(m.lastResize)
+		cmd = m.addTab(getFeedView(m.ctx, m.renderer, m.db, m.user, msg.find, msg.name))
 		return m, cmd
 	case OpenHomeMsg:
 		for _, tab  := range m.tabs {
@@ -156,18 +302,16 @@ func (m BoardModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 				return m, nil
 			}
 		}
-		m.tabs = append(m.tabs, getProfileView(m.renderer, m.db, m.user.username, m.user))
-		m.tabs[len(m.tabs)-1].Model, cmd = m.tabs[len(m.tabs)-1].Model.Update(m.lastResize)
-		return m, nil
+		cmd = m.addTab(getProfileView(m.ctx, m.renderer, m.db, m.user.username, m.user))
+		return m, cmd
 	case OpenProfileMsg:
 		for _, tab  := range m.tabs {
 			if tab.Name == msg.username {
 				return m, nil
 			}
 		}
-		m.tabs = append(m.tabs, getProfileView(m.renderer, m.db, msg.username, m.user))
-		m.tabs[len(m.tabs)-1].Model, cmd = m.tabs[len(m.tabs)-1].Model.Update(m.lastResize)
-		return m, nil
+		cmd = m.addTab(getProfileView(m.ctx, m.renderer, m.db, msg.username, m.user))
+		return m, cmd
 	case TabMoveMsg:
 
 		if msg.dir == left {
@@ -182,9 +326,8 @@ func (m BoardModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 				return m, nil
 			}
 		}
-		m.tabs = append(m.tabs, getEditProfileModel(m.renderer, m.db, m.user))
-		m.tabs[len(m.tabs)-1].Model, cmd = m.tabs[len(m.tabs)-1].Model.Update(m.lastResize)
-		return m, nil
+		cmd = m.addTab(getEditProfileModel(m.ctx, m.renderer, m.db, m.user))
+		return m, cmd
 	case CloseEditMsg:
 		found := false
 		index := 0
@@ -198,26 +341,31 @@ func (m BoardModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		if !found {
 			return m, nil
 		}
-		m.tabs = append(m.tabs[:index], m.tabs[index+1:]...)
-		if index == len(m.tabs) && index != 0 {
-			m.currentTab = m.currentTab - 1
-		}
+		m.removeTab(index)
 		m.user.description = sql.NullString{Valid: true, String: msg.description}
 		m.user.location = sql.NullString{Valid: true, String: msg.location}
+		m.user.expandWarnings = msg.expandWarnings
 		return m, nil
 	case OpenPostMsg:
-		m.tabs = append(m.tabs, getPostView(m.renderer, m.db, msg.postId, m.user))
-		m.tabs[len(m.tabs)-1].Model, cmd = m.tabs[len(m.tabs)-1].Model.Update(m.lastResize)
-		return m, nil
+		cmd = m.addTab(getPostView(m.ctx, m.renderer, m.db, msg.postId, m.user))
+		return m, cmd
 	case OpenSearch:
-		m.tabs = append(m.tabs, getSearchView(m.renderer, m.db, m.user))
-		m.tabs[len(m.tabs)-1].Model, cmd = m.tabs[len(m.tabs)-1].Model.Update(m.lastResize)
-		return m, nil
+		cmd = m.addTab(getSearchView(m.ctx, m.renderer, m.db, m.user))
+		return m, cmd
 	}
-	if len(m.tabs) > 0 {
-		m.tabs[m.currentTab].Model, cmd = m.tabs[m.currentTab].Model.Update(msg)
+	if _, ok := msg.(tea.KeyMsg); ok {
+		if len(m.tabs) > 0 {
+			m.tabs[m.currentTab].Model, cmd = m.tabs[m.currentTab].Model.Update(msg)
+		}
+		return m, cmd
+	}
+	// results of commands are addressed by tab id, so every
+	// tab gets a chance to pick up its own
+	var cmds []tea.Cmd = make([]tea.Cmd, len(m.tabs))
+	for i := range m.tabs {
+		m.tabs[i].Model, cmds[i] = m.tabs[i].Model.Update(msg)
 	}
-	return m, cmd
+	return m, tea.Batch(cmds...)
 }
 
 func (m BoardModel) View() string {
@@ -229,15 +377,29 @@ func (m BoardModel) View() string {
 
 	var tabs []string = make([]string, len(m.tabs))
 	for i, tab := range m.tabs {
+		name := tab.Name
+		if titled, ok := tab.Model.(Titled); ok {
+			name = titled.Title()
+		}
+		name = SanitizeLine(name)
 		if i == m.currentTab {
-			tabs[i] = m.aTabStyle.Render(tab.Name)
+			tabs[i] = m.aTabStyle.Render(name)
 		} else {
-			tabs[i] = m.tabStyle.Render(tab.Name)
+			tabs[i] = m.tabStyle.Render(name)
 		}
 		
 	}
 	row := lipgloss.JoinHorizontal(lipgloss.Left, tabs...)
 	row = lipgloss.JoinVertical(lipgloss.Top, row, currentTab, info)
+	if m.announcements.Height() > 0 {
+		row = lipgloss.JoinVertical(lipgloss.Top, m.announcements.View(m.lastResize.Width), row)
+	}
+	if m.readOnly {
+		row = lipgloss.JoinVertical(lipgloss.Top, m.noticeStyle.Render(readOnlyNotice), row)
+	}
+	if m.notice != "" {
+		row = lipgloss.JoinVertical(lipgloss.Top, m.noticeStyle.Render(m.notice), row)
+	}
 	return row
 }
 
@@ -269,7 +431,7 @@ func openFeed(feed FeedType) tea.Cmd {
 	return func() tea.Msg {
 		switch (feed) {
 			case allFeed: return OpenFeedMsg{name: "Feed", find: FindAllPosts};
-			case followedFeed: return OpenFeedMsg{name: "Follows", find: FindFollowedPosts};
+			case followedFeed: return OpenFeedMsg{name: "Follows", find: FindFollowsFeed};
 			case likedFeed: return OpenFeedMsg{name: "Likes", find: FindLikedPosts};
 			case repliesFeed: return OpenFeedMsg{name: "Replies", find: FindAllRepliesToUserPosts};
 			default: return OpenFeedMsg{name: "Feed", find: FindAllPosts};
@@ -320,11 +482,12 @@ func editProfile() tea.Msg {
 type CloseEditMsg struct {
 	description string
 	location string
+	expandWarnings bool
 }
 
-func closeEdit(description string, location string) tea.Cmd {
+func closeEdit(description string, location string, expandWarnings bool) tea.Cmd {
 	return func() tea.Msg {
-		return CloseEditMsg{description: description, location: location}
+		return CloseEditMsg{description: description, location: location, expandWarnings: expandWarnings}
 	}
 }
 
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/charmbracelet/log"
+	gossh "golang.org/x/crypto/ssh"
+)
+
+// BootstrapAdministrator provisions the administrator named by
+// ADMIN_USERNAME, logging in with the key in ADMIN_PUBLIC_KEY
+// or ADMIN_PUBLIC_KEY_FILE, so a fresh instance can be
+// moderated. Without ADMIN_USERNAME it does nothing.
+func BootstrapAdministrator(db *sql.DB) error {
+	username := GetEnvOrDefault("ADMIN_USERNAME", "")
+	if username == "" {
+		return nil
+	}
+	if err := nameValidator(username); err != nil {
+		return fmt.Errorf("ADMIN_USERNAME: %w", err)
+	}
+	authorizedKey := GetEnvOrDefault("ADMIN_PUBLIC_KEY", "")
+	if path := GetEnvOrDefault("ADMIN_PUBLIC_KEY_FILE", ""); path != "" {
+		content, err := os.ReadFile(path)
+		if err != nil {
+			return fmt.Errorf("ADMIN_PUBLIC_KEY_FILE: %w", err)
+		}
+		authorizedKey = string(content)
+	}
+	if authorizedKey == "" {
+		return errors.New("ADMIN_USERNAME needs ADMIN_PUBLIC_KEY or ADMIN_PUBLIC_KEY_FILE")
+	}
+	key, _, _, _, err := gossh.ParseAuthorizedKey([]byte(authorizedKey))
+	if err != nil {
+		return fmt.Errorf("invalid administrator key: %w", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	created, err := ProvisionAdministrator(ctx, db, username, ConvertKey(key), GetEnvOrDefault("ADMIN_EMAIL", ""))
+	if err != nil {
+		return err
+	}
+	if created {
+		log.Info("Created administrator", "user", username)
+	} else {
+		log.Info("Administrator is set up", "user", username)
+	}
+	return nil
+}
@@ -0,0 +1,163 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"strings"
+
+	"github.com/charmbracelet/log"
+	"github.com/charmbracelet/ssh"
+	gossh "golang.org/x/crypto/ssh"
+)
+
+// trustedCAs sign user certificates accepted instead of
+// registered keys. Set from TRUSTED_USER_CA_KEYS.
+var trustedCAs []gossh.PublicKey
+
+// LoadTrustedCAs reads CA public keys in authorized_keys format.
+func LoadTrustedCAs(path string) error {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	var keys []gossh.PublicKey
+	for len(bytes.TrimSpace(content)) > 0 {
+		key, _, _, rest, err := gossh.ParseAuthorizedKey(content)
+		if err != nil {
+			return fmt.Errorf("invalid CA key in %s: %w", path, err)
+		}
+		keys = append(keys, key)
+		content = rest
+	}
+	if len(keys) == 0 {
+		return fmt.Errorf("no CA keys in %s", path)
+	}
+	trustedCAs = keys
+	log.Info("Trusting user certificates", "authorities", len(keys))
+	return nil
+}
+
+func isTrustedCA(key gossh.PublicKey) bool {
+	for _, ca := range trustedCAs {
+		if bytes.Equal(ca.Marshal(), key.Marshal()) {
+			return true
+		}
+	}
+	return false
+}
+
+// sourceAddressOption is the only critical option honoured;
+// certificates with any other are refused.
+const sourceAddressOption = "source-address"
+
+// CheckCertificate tells whether cert lets username log in from
+// addr: it must be a user certificate signed by a trusted CA,
+// currently valid, naming username among its principals.
+func CheckCertificate(cert *gossh.Certificate, username string, addr net.Addr) error {
+	if cert.CertType != gossh.UserCert {
+		return errors.New("not a user certificate")
+	}
+	if !isTrustedCA(cert.SignatureKey) {
+		return errors.New("certificate not signed by a trusted authority")
+	}
+	if len(cert.ValidPrincipals) == 0 {
+		return errors.New("certificate has no principals")
+	}
+	checker := gossh.CertChecker{SupportedCriticalOptions: []string{sourceAddressOption}}
+	if err := checker.CheckCert(username, cert); err != nil {
+		return err
+	}
+	if allowed, ok := cert.CriticalOptions[sourceAddressOption]; ok {
+		return checkSourceAddress(addr, allowed)
+	}
+	return nil
+}
+
+// checkSourceAddress matches addr against the comma separated
+// addresses and CIDR ranges of the source-address option.
+func checkSourceAddress(addr net.Addr, allowed string) error {
+	ip := net.ParseIP(remoteIp(addr))
+	if ip == nil {
+		return fmt.Errorf("unknown client address %s", addr)
+	}
+	for _, source := range strings.Split(allowed, ",") {
+		if exact := net.ParseIP(source); exact != nil && exact.Equal(ip) {
+			return nil
+		}
+		if _, network, err := net.ParseCIDR(source); err == nil && network.Contains(ip) {
+			return nil
+		}
+	}
+	return fmt.Errorf("address %s not allowed by the certificate", ip)
+}
+
+// GetCertificateAuth logs in users with a certificate from a
+// trusted CA, creating their verified account on first login.
+func GetCertificateAuth(context ssh.Context, db *sql.DB, cert *gossh.Certificate, username string) bool {
+	if err := CheckCertificate(cert, username, context.RemoteAddr()); err != nil {
+		log.Warn("Certificate refused", "user", username, "serial", cert.Serial, "key id", cert.KeyId, "error", err)
+		authAttempts.With("method", "certificate", "result", "failure").Add(1)
+		return false
+	}
+	user, err := GetUserByUsername(context, db, username)
+	if errors.Is(err, ErrNotFound) {
+		user, err = provisionCertificateUser(context, db, cert, username)
+	}
+	if err != nil {
+		log.Error("Could not log in with certificate", "user", username, "error", err)
+		authAttempts.With("method", "certificate", "result", "error").Add(1)
+		return false
+	}
+	if user.suspended {
+		log.Warn("Suspended user tried to log in", "user", username)
+		authAttempts.With("method", "certificate", "result", "suspended").Add(1)
+		return false
+	}
+	if !certificateOwnsAccount(cert, user) {
+		log.Warn("Certificate for an account registered with another key", "user", username, "serial", cert.Serial, "key id", cert.KeyId)
+		authAttempts.With("method", "certificate", "result", "failure").Add(1)
+		return false
+	}
+	if !user.verified {
+		if err := AcceptUser(context, db, user); err != nil {
+			log.Error("Could not verify user from certificate", "user", username, "error", err)
+			authAttempts.With("method", "certificate", "result", "error").Add(1)
+			return false
+		}
+		user.verified = true
+	}
+	log.Info("Logged in with certificate", "user", username, "serial", cert.Serial, "key id", cert.KeyId)
+	context.SetValue("guest", false)
+	context.SetValue("verified", true)
+	context.SetValue("user", user)
+	authAttempts.With("method", "certificate", "result", "success").Add(1)
+	return true
+}
+
+// certificateOwnsAccount tells whether cert may log in as user.
+// The authority vouches for names it handed out, so accounts it
+// created accept any of its certificates; accounts registered
+// with a key, administrators included, only accept that key.
+func certificateOwnsAccount(cert *gossh.Certificate, user SavedUser) bool {
+	if user.certificate {
+		return true
+	}
+	stored, _, _, _, err := ssh.ParseAuthorizedKey([]byte(user.key))
+	return err == nil && ssh.KeysEqual(cert.Key, stored)
+}
+
+func provisionCertificateUser(ctx context.Context, db *sql.DB, cert *gossh.Certificate, username string) (SavedUser, error) {
+	if err := nameValidator(username); err != nil {
+		return SavedUser{}, fmt.Errorf("invalid username %q: %w", username, err)
+	}
+	if err := SaveCertificateUser(ctx, db, username, ConvertKey(cert.Key)); err != nil {
+		return SavedUser{}, err
+	}
+	NotifyUserRegistered(ctx, username)
+	return GetUserByUsername(ctx, db, username)
+}
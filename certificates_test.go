@@ -0,0 +1,42 @@
+package main
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"testing"
+
+	gossh "golang.org/x/crypto/ssh"
+)
+
+func testPublicKey(t *testing.T, seed byte) gossh.PublicKey {
+	t.Helper()
+	private := ed25519.NewKeyFromSeed(bytes.Repeat([]byte{seed}, ed25519.SeedSize))
+	key, err := gossh.NewPublicKey(private.Public())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return key
+}
+
+func TestCertificateOwnsAccount(t *testing.T) {
+	certKey, otherKey := testPublicKey(t, 1), testPublicKey(t, 2)
+	cert := &gossh.Certificate{Key: certKey, CertType: gossh.UserCert, ValidPrincipals: []string{"alice"}}
+	tests := []struct {
+		name string
+		user SavedUser
+		want bool
+	}{
+		{"created by a certificate", SavedUser{key: ConvertKey(otherKey), verified: true, certificate: true}, true},
+		{"registered with the same key", SavedUser{key: ConvertKey(certKey), verified: false}, true},
+		{"verified, registered with another key", SavedUser{key: ConvertKey(otherKey), verified: true}, false},
+		{"administrator with another key", SavedUser{key: ConvertKey(otherKey), verified: true, administrator: true}, false},
+		{"unverified, registered with another key", SavedUser{key: ConvertKey(otherKey), verified: false}, false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := certificateOwnsAccount(cert, test.user); got != test.want {
+				t.Errorf("certificateOwnsAccount() = %v, want %v", got, test.want)
+			}
+		})
+	}
+}
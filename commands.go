@@ -0,0 +1,317 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"time"
+
+	gossh "golang.org/x/crypto/ssh"
+)
+
+// runCommand runs a maintenance subcommand instead of the
+// server and returns the exit code.
+func runCommand(name string, args []string) int {
+	switch name {
+	case "reconcile":
+		return reconcileCommand(args)
+	case "import":
+		return importCommand(args)
+	case "webhook-receiver":
+		return webhookReceiverCommand(args)
+	case "admin":
+		return adminCommand(args)
+	case "seed":
+		return seedCommand(args)
+	case "rebuild-timelines":
+		return rebuildTimelinesCommand(args)
+	default:
+		fmt.Fprintf(os.Stderr, "unknown command %q\n", name)
+		fmt.Fprintln(os.Stderr, "usage: sshwitter [reconcile|import|webhook-receiver|admin|seed|rebuild-timelines]")
+		return 2
+	}
+}
+
+func reconcileCommand(args []string) int {
+	flags := flag.NewFlagSet("reconcile", flag.ContinueOnError)
+	batch := flags.Int("batch", 1000, "number of rows fixed in one statement")
+	dryRun := flags.Bool("dry-run", false, "only report discrepancies")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	if *batch <= 0 {
+		fmt.Fprintln(os.Stderr, "batch must be positive")
+		return 2
+	}
+
+	db, err := connectDB()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	defer db.Close()
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	report, err := Reconcile(ctx, db, *batch, *dryRun)
+	for _, d := range report.discrepancies {
+		fmt.Println(d.String())
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	action := "fixed"
+	if *dryRun {
+		action = "found"
+	}
+	fmt.Printf("checked %d posts and %d users, %s %d discrepancies\n",
+		report.posts, report.users, action, len(report.discrepancies))
+	return 0
+}
+
+// rebuildTimelinesCommand refills the materialized Follows
+// feeds used with TIMELINE_STRATEGY=fanout.
+func rebuildTimelinesCommand(args []string) int {
+	flags := flag.NewFlagSet("rebuild-timelines", flag.ContinueOnError)
+	defaultSize, _ := strconv.Atoi(GetEnvOrDefault("TIMELINE_SIZE", "500"))
+	size := flags.Int("size", defaultSize, "number of posts kept in every feed")
+	batch := flags.Int("batch", 1000, "number of users rebuilt in one transaction")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	if *size <= 0 || *batch <= 0 {
+		fmt.Fprintln(os.Stderr, "size and batch must be positive")
+		return 2
+	}
+
+	db, err := connectDB()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	defer db.Close()
+	CreateSchema(db)
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	users, err := RebuildHomeTimelines(ctx, db, *size, *batch)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	fmt.Printf("rebuilt home timelines of %d users\n", users)
+	return 0
+}
+
+func importCommand(args []string) int {
+	flags := flag.NewFlagSet("import", flag.ContinueOnError)
+	keepVerified := flags.Bool("keep-verified", false, "keep the account verified if it was on the exporting instance")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	if flags.NArg() != 1 {
+		fmt.Fprintln(os.Stderr, "usage: sshwitter import [-keep-verified] archive.json")
+		return 2
+	}
+
+	file, err := os.Open(flags.Arg(0))
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	defer file.Close()
+	var archive Archive
+	if err := json.NewDecoder(file).Decode(&archive); err != nil {
+		fmt.Fprintln(os.Stderr, "invalid archive:", err)
+		return 1
+	}
+
+	db, err := connectDB()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	defer db.Close()
+	CreateSchema(db)
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	report, err := ImportArchive(ctx, db, archive, *keepVerified)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, ErrorMessage(err, err.Error()))
+		return 1
+	}
+	if _, err := Reconcile(ctx, db, 1000, false); err != nil {
+		fmt.Fprintln(os.Stderr, "imported, but counters are not reconciled:", err)
+		return 1
+	}
+	if err := RequestTimelineRebuild(ctx, db); err != nil {
+		fmt.Fprintln(os.Stderr, "imported, but home timelines are not rebuilt:", err)
+		return 1
+	}
+	fmt.Printf("imported %s: %d posts, %d likes, %d follows, %d followers (%d references not found, %d replies imported as posts)\n",
+		archive.Profile.Username, len(archive.Posts), len(archive.Likes),
+		len(archive.Follows), len(archive.Followers), report.skipped, report.detached)
+	return 0
+}
+
+func seedCommand(args []string) int {
+	flags := flag.NewFlagSet("seed", flag.ContinueOnError)
+	seed := flags.Int64("seed", 1, "random seed; the same seed gives the same data")
+	users := flags.Int("users", 50, "number of users")
+	posts := flags.Int("posts", 20, "average number of posts per user, replies included")
+	replies := flags.Float64("replies", 0.3, "fraction of posts that are replies")
+	likes := flags.Int("likes", 30, "average number of likes per user")
+	follows := flags.Int("follows", 10, "average number of follows per user")
+	days := flags.Int("days", 30, "number of days the activity is spread over")
+	// A fixed default, so the same seed always gives the same data.
+	until := flags.String("until", "2024-06-01", "day the activity ends, as yyyy-mm-dd")
+	keys := flags.String("keys", "", "directory to write the private keys of the users to")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	end, err := time.Parse("2006-01-02", *until)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "until must be a date like 2024-06-01")
+		return 2
+	}
+	if *users < 0 || *posts < 0 || *likes < 0 || *follows < 0 || *days <= 0 || *replies < 0 || *replies > 1 {
+		fmt.Fprintln(os.Stderr, "counts must not be negative, days must be positive and replies between 0 and 1")
+		return 2
+	}
+
+	data := GenerateSeed(SeedOptions{
+		seed:    *seed,
+		users:   *users,
+		posts:   *posts,
+		replies: *replies,
+		likes:   *likes,
+		follows: *follows,
+		days:    *days,
+		until:   end,
+	})
+	if *keys != "" {
+		if err := writeSeedKeys(*keys, data); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+	}
+
+	db, err := connectDB()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	defer db.Close()
+	CreateSchema(db)
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	if err := SaveSeed(ctx, db, data); err != nil {
+		fmt.Fprintln(os.Stderr, ErrorMessage(err, err.Error()))
+		return 1
+	}
+	if _, err := Reconcile(ctx, db, 1000, false); err != nil {
+		fmt.Fprintln(os.Stderr, "seeded, but counters are not reconciled:", err)
+		return 1
+	}
+	if err := RequestTimelineRebuild(ctx, db); err != nil {
+		fmt.Fprintln(os.Stderr, "seeded, but home timelines are not rebuilt:", err)
+		return 1
+	}
+	fmt.Printf("seeded %d users, %d posts (%d replies), %d likes and %d follows\n",
+		len(data.users), len(data.posts), data.Replies(), len(data.likes), len(data.follows))
+	return 0
+}
+
+// writeSeedKeys saves the key pair of every seeded user, so
+// developers can log in as them with `ssh -i DIR/NAME NAME@host`.
+func writeSeedKeys(dir string, data Seed) error {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return err
+	}
+	for _, user := range data.users {
+		block, err := gossh.MarshalPrivateKey(user.privateKey, user.username)
+		if err != nil {
+			return err
+		}
+		path := filepath.Join(dir, user.username)
+		if err := os.WriteFile(path, pem.EncodeToMemory(block), 0o600); err != nil {
+			return err
+		}
+		if err := os.WriteFile(path+".pub", []byte(user.key+"\n"), 0o644); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// webhookReceiverCommand is a local stand-in for a webhook
+// endpoint. It prints every delivery, checks its signature and
+// can fail the first requests to exercise retries.
+func webhookReceiverCommand(args []string) int {
+	flags := flag.NewFlagSet("webhook-receiver", flag.ContinueOnError)
+	addr := flags.String("addr", "127.0.0.1:9999", "address to listen on")
+	secret := flags.String("secret", "", "webhook secret used to check signatures")
+	fail := flags.Int("fail", 0, "number of requests answered with 500 before accepting")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+
+	var mu sync.Mutex
+	failures := *fail
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(io.LimitReader(r.Body, 1<<20))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		signature := r.Header.Get(signatureHeader)
+		valid := *secret == "" || VerifyWebhookSignature(*secret, body, signature)
+		mu.Lock()
+		failing := failures > 0
+		if failing {
+			failures -= 1
+		}
+		mu.Unlock()
+		fmt.Printf("delivery %s event %s signature valid %t failing %t\n%s\n",
+			r.Header.Get(deliveryHeader), r.Header.Get(eventHeader), valid, failing, body)
+		switch {
+		case !valid:
+			http.Error(w, "invalid signature", http.StatusUnauthorized)
+		case failing:
+			http.Error(w, "failing on purpose", http.StatusInternalServerError)
+		default:
+			w.WriteHeader(http.StatusNoContent)
+		}
+	})
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	server := &http.Server{Addr: *addr, Handler: handler}
+	go func() {
+		<-ctx.Done()
+		server.Shutdown(context.Background())
+	}()
+	fmt.Println("listening on", *addr)
+	if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	return 0
+}
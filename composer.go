@@ -0,0 +1,222 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/bubbles/textarea"
+	"github.com/charmbracelet/bubbles/textinput"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/charmbracelet/log"
+)
+
+type Composer struct {
+	text             textarea.Model
+	warning          textinput.Model
+	hasWarning       bool
+	poll             PollInput
+	hasPoll          bool
+	err              error
+	errorStyle       lipgloss.Style
+	labelStyle       lipgloss.Style
+}
+
+func CreateComposer(renderer *lipgloss.Renderer) Composer {
+	textInput := textarea.New()
+	textInput.Placeholder = "Type a message..."
+
+	textInput.CharLimit = 280
+	textInput.SetWidth(30)
+	textInput.SetHeight(3)
+	textInput.FocusedStyle.CursorLine = lipgloss.NewStyle()
+	textInput.ShowLineNumbers = false
+
+	warningInput := textinput.New()
+	warningInput.Placeholder = "Content warning"
+	warningInput.Prompt = ""
+	warningInput.CharLimit = 100
+	warningInput.Width = 28
+
+	errorStyle := renderer.NewStyle().
+			Foreground(lipgloss.Color("#cc0000"))
+	labelStyle := renderer.NewStyle().Foreground(lipgloss.Color("8"))
+
+	return Composer{
+		text: textInput,
+		warning: warningInput,
+		hasWarning: false,
+		poll: CreatePollInput(renderer),
+		hasPoll: false,
+		errorStyle: errorStyle,
+		labelStyle: labelStyle,
+	}
+}
+
+func (c *Composer) Focus() tea.Cmd {
+	return c.text.Focus()
+}
+
+func (c *Composer) Blur() {
+	c.text.Blur()
+	c.warning.Blur()
+	c.poll.Blur()
+}
+
+func (c *Composer) Reset() {
+	c.Blur()
+	c.text.Reset()
+	c.warning.Reset()
+	c.hasWarning = false
+	c.poll.Reset()
+	c.hasPoll = false
+	c.err = nil
+}
+
+// SetDraft fills the composer with a saved draft.
+func (c *Composer) SetDraft(draft Draft) {
+	c.text.SetValue(draft.content)
+	if draft.contentWarning != "" {
+		c.hasWarning = true
+		c.warning.SetValue(draft.contentWarning)
+	}
+}
+
+func (c Composer) Value() string {
+	return c.text.Value()
+}
+
+func (c Composer) ContentWarning() string {
+	if !c.hasWarning {
+		return ""
+	}
+	return strings.TrimSpace(c.warning.Value())
+}
+
+func (c Composer) HasPoll() bool {
+	return c.hasPoll
+}
+
+// Validate checks the attached poll, if any, and keeps the error
+// so it is shown under the composer.
+func (c *Composer) Validate() bool {
+	c.err = nil
+	if c.hasPoll {
+		c.err = c.poll.Validate()
+	}
+	return c.err == nil
+}
+
+// Save stores the composed post together with its poll and returns
+// the post as it should be pushed onto the author's timeline.
+func (c Composer) Save(ctx context.Context, db *sql.DB, user SavedUser) (Post, error) {
+	text := c.text.Value()
+	warning := c.ContentWarning()
+	var id int64
+	var err error
+	if c.hasPoll {
+		duration, _ := c.poll.Duration()
+		id, err = SavePostWithPoll(ctx, db, user, text, warning, c.poll.Options(), duration)
+	} else {
+		id, err = SavePost(ctx, db, user, text, warning)
+	}
+	if err != nil {
+		return Post{}, err
+	}
+
+	posts := []Post{{
+		id: id,
+		userId: user.id,
+		content: text,
+		contentWarning: sql.NullString{Valid: warning != "", String: warning},
+		username: user.username,
+		createdAt: time.Now(),
+	}}
+	if c.hasPoll {
+		if err := AttachPolls(ctx, db, posts, user); err != nil {
+			log.Error(err)
+		}
+	}
+	return posts[0], nil
+}
+
+func (c Composer) Update(msg tea.KeyMsg) (Composer, tea.Cmd) {
+	var cmd tea.Cmd
+	switch msg.String() {
+	case "ctrl+g":
+		c.hasWarning = !c.hasWarning
+		if !c.hasWarning {
+			c.warning.Reset()
+			c.Blur()
+			return c, c.text.Focus()
+		}
+		return c, nil
+	case "ctrl+o":
+		c.hasPoll = !c.hasPoll
+		c.err = nil
+		if !c.hasPoll {
+			c.poll.Reset()
+			c.Blur()
+			return c, c.text.Focus()
+		}
+		return c, nil
+	case "tab":
+		return c, c.next()
+	}
+
+	if c.warning.Focused() {
+		c.warning, cmd = c.warning.Update(msg)
+	} else if c.poll.Focused() {
+		c.poll, cmd = c.poll.Update(msg)
+	} else {
+		c.text, cmd = c.text.Update(msg)
+	}
+	return c, cmd
+}
+
+// next cycles focus through the message, the content warning
+// and the poll fields, skipping the ones that aren't attached.
+func (c *Composer) next() tea.Cmd {
+	if c.text.Focused() {
+		c.text.Blur()
+		if c.hasWarning {
+			return c.warning.Focus()
+		}
+	} else if c.warning.Focused() {
+		c.warning.Blur()
+	}
+	if c.hasPoll {
+		if inPoll, cmd := c.poll.Next(); inPoll {
+			return cmd
+		}
+	}
+	return c.text.Focus()
+}
+
+func (c Composer) View() string {
+	doc := strings.Builder{}
+	if c.hasWarning {
+		doc.WriteString(c.labelStyle.Render("CW: "))
+		doc.WriteString(c.warning.View())
+		doc.WriteString("\n")
+	}
+	doc.WriteString(c.text.View())
+	if c.hasPoll {
+		doc.WriteString("\n")
+		doc.WriteString(c.poll.View())
+	}
+	if c.err != nil {
+		doc.WriteString("\n")
+		doc.WriteString(c.errorStyle.Render(c.err.Error()))
+	}
+	doc.WriteString("\n")
+	doc.WriteString(c.labelStyle.Render("ctrl+g: content warning • ctrl+o: poll"))
+	doc.WriteString("\n")
+	return doc.String()
+}
+
+func (c Composer) Height() int {
+	return lipgloss.Height(c.View())
+}
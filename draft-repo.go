@@ -0,0 +1,70 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/charmbracelet/log"
+)
+
+// Draft is an unpublished post or reply kept while the server
+// restarts. Every user has at most one.
+type Draft struct {
+	content         string
+	contentWarning  string
+	parentId        sql.NullInt64
+}
+
+func CreateDraftTable(db *sql.DB) {
+	createTableSQL := `
+	CREATE TABLE IF NOT EXISTS drafts (
+		user_id INTEGER PRIMARY KEY REFERENCES users(id) ON DELETE CASCADE,
+		content TEXT NOT NULL,
+		content_warning VARCHAR(100),
+		parent_id INTEGER REFERENCES posts(id) ON DELETE CASCADE,
+		saved_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP
+	);`
+
+	_, err := db.Exec(createTableSQL)
+	if err != nil {
+		log.Fatalf("Failed to create table: %v", err)
+	}
+
+	log.Info("Table 'drafts' created successfully!")
+}
+
+func SaveDraft(ctx context.Context, db *sql.DB, user SavedUser, draft Draft) error {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+	log.Info("Saving draft", "user", user.username)
+	query := `
+	INSERT INTO drafts (user_id, content, content_warning, parent_id)
+	VALUES ($1, $2, NULLIF($3, ''), $4)
+	ON CONFLICT (user_id) DO UPDATE
+	SET content = EXCLUDED.content, content_warning = EXCLUDED.content_warning,
+	    parent_id = EXCLUDED.parent_id, saved_at = CURRENT_TIMESTAMP`
+	_, err := db.ExecContext(ctx, query, user.id, Sanitize(draft.content), Sanitize(draft.contentWarning), draft.parentId)
+	if err != nil {
+		log.Errorf("failed to save draft: %v", err)
+		return fmt.Errorf("failed to save draft: %w", mapDbError(err))
+	}
+	return nil
+}
+
+// TakeDraft removes the saved draft of user and returns it.
+func TakeDraft(ctx context.Context, db *sql.DB, user SavedUser) (Draft, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+	var draft Draft
+	var warning sql.NullString
+	query := `
+	DELETE FROM drafts WHERE user_id = $1
+	RETURNING content, content_warning, parent_id`
+	err := db.QueryRowContext(ctx, query, user.id).Scan(&draft.content, &warning, &draft.parentId)
+	if err != nil {
+		return draft, mapDbError(err)
+	}
+	draft.contentWarning = warning.String
+	return draft, nil
+}
@@ -0,0 +1,178 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/log"
+	"github.com/charmbracelet/ssh"
+	"github.com/charmbracelet/wish"
+	"github.com/charmbracelet/wish/bubbletea"
+)
+
+// ProgramRegistry keeps the running programs of interactive
+// sessions, so the server can send them messages.
+type ProgramRegistry struct {
+	mu        sync.Mutex
+	programs  map[*tea.Program]struct{}
+	empty     chan struct{}
+}
+
+var programs = &ProgramRegistry{programs: map[*tea.Program]struct{}{}}
+
+// draining is set once shutdown has started; readiness
+// reports it so no new sessions are routed here.
+var draining atomic.Bool
+
+func (r *ProgramRegistry) Add(p *tea.Program) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.programs[p] = struct{}{}
+}
+
+func (r *ProgramRegistry) Remove(p *tea.Program) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.programs, p)
+	if len(r.programs) == 0 && r.empty != nil {
+		close(r.empty)
+		r.empty = nil
+	}
+}
+
+func (r *ProgramRegistry) Count() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return len(r.programs)
+}
+
+func (r *ProgramRegistry) Broadcast(msg tea.Msg) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for p := range r.programs {
+		go p.Send(msg)
+	}
+}
+
+// Wait blocks until every program has ended or ctx is done.
+func (r *ProgramRegistry) Wait(ctx context.Context) error {
+	r.mu.Lock()
+	if len(r.programs) == 0 {
+		r.mu.Unlock()
+		return nil
+	}
+	if r.empty == nil {
+		r.empty = make(chan struct{})
+	}
+	empty := r.empty
+	r.mu.Unlock()
+	select {
+	case <-empty:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func makeProgramHandler(db *sql.DB) bubbletea.ProgramHandler {
+	return func(s ssh.Session) *tea.Program {
+		if draining.Load() {
+			wish.Fatalln(s, "The server is restarting, please try again in a moment.")
+			return nil
+		}
+		model, options := teaHandler(s, db)
+		p := tea.NewProgram(model, append(options, bubbletea.MakeOptions(s)...)...)
+		programs.Add(p)
+		go func() {
+			<-s.Context().Done()
+			programs.Remove(p)
+		}()
+		return p
+	}
+}
+
+// ShutdownNoticeMsg tells sessions the server stops at a
+// given time.
+type ShutdownNoticeMsg struct {
+	at time.Time
+}
+
+func (msg ShutdownNoticeMsg) String() string {
+	seconds := int(time.Until(msg.at).Round(time.Second).Seconds())
+	return fmt.Sprintf("Server restarting in %d seconds, your draft will be kept", max(seconds, 0))
+}
+
+// tick sends the notice again a second later, so the
+// countdown is re-rendered.
+func (msg ShutdownNoticeMsg) tick() tea.Cmd {
+	return tea.Tick(time.Second, func(time.Time) tea.Msg {
+		return msg
+	})
+}
+
+// DrainMsg asks sessions to save their drafts and quit.
+type DrainMsg struct{}
+
+// Drafter is implemented by tabs holding text that wasn't
+// published yet.
+type Drafter interface {
+	Draft() (Draft, bool)
+}
+
+func saveDraftAndQuit(db *sql.DB, user SavedUser, draft Draft, found bool) tea.Cmd {
+	return func() tea.Msg {
+		if found {
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			if err := SaveDraft(ctx, db, user, draft); err != nil {
+				log.Error("Could not save draft", "user", user.username, "error", err)
+			}
+		}
+		return tea.Quit()
+	}
+}
+
+type DraftLoadedMsg struct {
+	draft Draft
+}
+
+func loadDraft(ctx context.Context, db *sql.DB, user SavedUser) tea.Cmd {
+	return func() tea.Msg {
+		if ctx.Err() != nil {
+			return nil
+		}
+		draft, err := TakeDraft(ctx, db, user)
+		if err != nil {
+			if !errors.Is(err, ErrNotFound) {
+				log.Error("Could not load draft", "user", user.username, "error", err)
+			}
+			return nil
+		}
+		return DraftLoadedMsg{draft: draft}
+	}
+}
+
+// Drain warns every session, waits for notice, then makes the
+// sessions save their drafts and quit. It returns once they
+// have ended or ctx is done.
+func Drain(ctx context.Context, notice time.Duration) {
+	draining.Store(true)
+	if notice > 0 && programs.Count() > 0 {
+		log.Info("Draining sessions", "notice", notice)
+		programs.Broadcast(ShutdownNoticeMsg{at: time.Now().Add(notice)})
+		select {
+		case <-time.After(notice):
+		case <-ctx.Done():
+		}
+	}
+	programs.Broadcast(DrainMsg{})
+	if err := programs.Wait(ctx); err != nil {
+		log.Warn("Sessions still open after draining", "error", err)
+	}
+}
@@ -1,17 +1,25 @@
 package main
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
 
 	"github.com/charmbracelet/bubbles/textinput"
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/charmbracelet/lipgloss"
+	"github.com/charmbracelet/log"
 )
 
 type EditProfileModel struct {
+	id             int64
+	ctx            context.Context
+	cancel         context.CancelFunc
 	descriptionInput CustomInput
 	locationInput  CustomInput
+	confirmInput   CustomInput
+	confirming     bool
+	expandWarnings bool
 	elems          int
 	current        int
 	err            error
@@ -19,11 +27,14 @@ type EditProfileModel struct {
 	headerStyle    lipgloss.Style
 	subheaderStyle lipgloss.Style
 	buttonStyle    lipgloss.Style
+	deleteStyle    lipgloss.Style
 	db             *sql.DB
 	user           SavedUser
+	banner         ErrorBanner
+	loader         Loader
 }
 
-func getEditProfileModel(renderer *lipgloss.Renderer, db *sql.DB, user SavedUser) Tab {
+func getEditProfileModel(parent context.Context, renderer *lipgloss.Renderer, db *sql.DB, user SavedUser) Tab {
 	descriptionInput := CreateCustomInput(renderer, "Description", "Describe yourself", descriptionValidator, true)
 	if user.description.Valid {
 		descriptionInput.Input.SetValue(user.description.String)
@@ -33,6 +44,13 @@ func getEditProfileModel(renderer *lipgloss.Renderer, db *sql.DB, user SavedUser
 		locationInput.Input.SetValue(user.location.String)
 	}
 
+	confirmInput := CreateCustomInput(renderer, "Type your username to confirm", user.username, func(s string) error {
+		if s != user.username {
+			return fmt.Errorf("doesn't match your username")
+		}
+		return nil
+	}, false)
+
 	headerStyle := renderer.NewStyle().Foreground(lipgloss.Color("10"))
 	subheaderStyle := renderer.NewStyle().Foreground(lipgloss.Color("8"))
 
@@ -41,18 +59,28 @@ func getEditProfileModel(renderer *lipgloss.Renderer, db *sql.DB, user SavedUser
 		Width(30).
 		Align(lipgloss.Right)
 
+	ctx, cancel := context.WithCancel(parent)
+
 	return Tab{
 		Model: EditProfileModel{
+			id:               nextTabId(),
+			ctx:              ctx,
+			cancel:           cancel,
 			descriptionInput: descriptionInput,
 			locationInput:    locationInput,
-			elems:            3,
+			confirmInput:     confirmInput,
+			expandWarnings:   user.expandWarnings,
+			elems:            5,
 			err:              nil,
 			input:            true,
 			headerStyle:      headerStyle,
 			subheaderStyle:   subheaderStyle,
 			buttonStyle:      buttonStyle,
+			deleteStyle:      renderer.NewStyle().Foreground(lipgloss.Color("#cc0000")),
 			db:               db,
 			user:             user,
+			banner:           CreateErrorBanner(renderer),
+			loader:           CreateLoader(renderer),
 		},
 		Name: "Edit profile",
 	}
@@ -62,11 +90,77 @@ func (m EditProfileModel) Init() tea.Cmd {
 	return textinput.Blink
 }
 
+func (m EditProfileModel) Close() {
+	m.cancel()
+}
+
+type ProfileSavedMsg struct {
+	tab            int64
+	description    string
+	location       string
+	expandWarnings bool
+	err            error
+}
+
+func saveProfile(ctx context.Context, tab int64, db *sql.DB, user SavedUser, description string, location string, expandWarnings bool) tea.Cmd {
+	return func() tea.Msg {
+		if ctx.Err() != nil {
+			return nil
+		}
+		err := UpdateUserData(ctx, db, user, description, location, expandWarnings)
+		return ProfileSavedMsg{
+			tab: tab,
+			description: description,
+			location: location,
+			expandWarnings: expandWarnings,
+			err: err,
+		}
+	}
+}
+
+type AccountDeletedMsg struct {
+	tab  int64
+	err  error
+}
+
+func deleteAccount(ctx context.Context, tab int64, db *sql.DB, user SavedUser) tea.Cmd {
+	return func() tea.Msg {
+		if ctx.Err() != nil {
+			return nil
+		}
+		err := DeleteAccount(ctx, db, user)
+		return AccountDeletedMsg{tab: tab, err: err}
+	}
+}
+
 func (m EditProfileModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
-	var cmds []tea.Cmd = make([]tea.Cmd, 2)
+	var cmds []tea.Cmd = make([]tea.Cmd, 4)
 
 	switch msg := msg.(type) {
 	case tea.KeyMsg:
+		if m.confirming {
+			switch msg.String() {
+			case "esc":
+				m.confirmInput.Blur()
+				m.confirmInput.Input.Reset()
+				m.confirming = false
+				m.input = false
+				return m, nil
+			case "enter":
+				if m.confirmInput.Input.Value() != m.user.username || m.loader.Loading() {
+					return m, nil
+				}
+				m.confirmInput.Blur()
+				cmd := tea.Batch(
+					m.loader.Start("Deleting account..."),
+					deleteAccount(m.ctx, m.id, m.db, m.user),
+				)
+				return m, cmd
+			}
+			var cmd tea.Cmd
+			m.confirmInput, cmd = m.confirmInput.Update(msg)
+			return m, cmd
+		}
 		switch msg.String() {
 		case "esc":
 			m.descriptionInput.Blur()
@@ -92,17 +186,24 @@ func (m EditProfileModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 					m.input = true
 					return m, m.locationInput.Focus()
 				} else if m.current == 2 {
-					if !m.Valid() {
+					m.expandWarnings = !m.expandWarnings
+					return m, nil
+				} else if m.current == 3 {
+					if !m.Valid() || m.loader.Loading() {
 						return m, nil
 					}
 					desc := m.descriptionInput.Input.Value()
 					loc := m.locationInput.Input.Value()
 					
-					err := UpdateUserData(m.db, m.user, desc, loc)
-					if err != nil {
-						return m, nil
-					}
-					return m, closeEdit(desc, loc)
+					cmd := tea.Batch(
+						m.loader.Start("Saving..."),
+						saveProfile(m.ctx, m.id, m.db, m.user, desc, loc, m.expandWarnings),
+					)
+					return m, cmd
+				} else if m.current == 4 {
+					m.confirming = true
+					m.input = true
+					return m, m.confirmInput.Focus()
 				}
 			} else {
 				m.descriptionInput.Blur()
@@ -110,11 +211,35 @@ func (m EditProfileModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 				m.input = false
 			}
 		}
+	case ProfileSavedMsg:
+		if msg.tab != m.id {
+			return m, nil
+		}
+		m.loader.Done()
+		if msg.err != nil {
+			m.banner.Show("Could not save your profile", msg.err)
+			return m, nil
+		}
+		return m, closeEdit(msg.description, msg.location, msg.expandWarnings)
+	case AccountDeletedMsg:
+		if msg.tab != m.id {
+			return m, nil
+		}
+		m.loader.Done()
+		if msg.err != nil {
+			m.banner.Show("Could not delete your account", msg.err)
+			m.confirmInput.Input.Focus()
+			return m, nil
+		}
+		log.Info("Account deleted, closing session", "user", m.user.username)
+		return m, tea.Quit
 	case error:
 		m.err = msg
 		return m, nil
 	}
 
+	m.loader, cmds[2] = m.loader.Update(msg)
+	m.confirmInput, cmds[3] = m.confirmInput.Update(msg)
 	m.descriptionInput, cmds[0] = m.descriptionInput.Update(msg)
 	m.locationInput, cmds[1] = m.locationInput.Update(msg)
 	return m, tea.Batch(cmds...)
@@ -123,25 +248,55 @@ func (m EditProfileModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 func (m EditProfileModel) View() string {
 	description := m.descriptionInput.View(m.current == 0)
 	location := m.locationInput.View(m.current == 1)
+	expand := getButtonPrefix(m.current == 2) + m.RenderExpandWarnings() + " Always expand content warnings"
 
 	var button string
 
-	if m.current == 2 {
+	if m.current == 3 {
 		button = m.buttonStyle.
-			Render(getButtonPrefix(m.current == 2) + "[ Save ]")
+			Render(getButtonPrefix(m.current == 3) + "[ Save ]")
 	} else {
 		button = m.buttonStyle.
 			Foreground(lipgloss.Color("8")).
 			Render("[ Save ]")
 	}
 
+	var deleteAccount string
+	if m.confirming {
+		deleteAccount = m.deleteStyle.Render("This removes your account, likes and follows for good.") +
+			"\n" +
+			m.confirmInput.View(true) +
+			"\n" +
+			m.subheaderStyle.Render("enter: delete • esc: cancel")
+	} else if m.current == 4 {
+		deleteAccount = getButtonPrefix(true) + m.deleteStyle.Render("[ Delete account ]")
+	} else {
+		deleteAccount = "  " + m.subheaderStyle.Render("[ Delete account ]")
+	}
+
 	return m.headerStyle.Render("Edit your profile") + "\n" +
 		"\n\n" +
 		description +
 		"\n" +
 		location +
 		"\n" +
-		button
+		expand +
+		"\n" +
+		button +
+		"\n\n" +
+		deleteAccount +
+		"\n" +
+		m.loader.View() +
+		m.banner.View()
+}
+
+func (m EditProfileModel) RenderExpandWarnings() string {
+	style := lipgloss.NewStyle().
+		Foreground(lipgloss.Color("#34b233"))
+	if m.expandWarnings {
+		return "[" + style.Render("✔") + "]"
+	}
+	return "[ ]"
 }
 
 func (m EditProfileModel) Valid() bool {
@@ -0,0 +1,154 @@
+package main
+
+import (
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/charmbracelet/log"
+)
+
+func getNotFoundView(renderer *lipgloss.Renderer, name string, message string) (Tab) {
+	headerStyle := renderer.NewStyle().
+		Foreground(lipgloss.Color("5")).
+		Bold(true)
+	quitStyle := renderer.NewStyle().Foreground(lipgloss.Color("8"))
+
+	return Tab{
+		Model: NotFoundModel{
+			headerStyle: headerStyle,
+			quitStyle: quitStyle,
+			message: message,
+		},
+		Name: name,
+	}
+}
+
+type NotFoundModel struct {
+	headerStyle  lipgloss.Style
+	quitStyle    lipgloss.Style
+	message      string
+}
+
+func (m NotFoundModel) Init() tea.Cmd {
+	return nil
+}
+
+func (m NotFoundModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	return m, nil
+}
+
+func (m NotFoundModel) View() string {
+	doc := strings.Builder{}
+	doc.WriteString(m.headerStyle.Render("404"))
+	doc.WriteString("\n")
+	doc.WriteString(m.message)
+	doc.WriteString("\n\n")
+	doc.WriteString(m.quitStyle.Render("alt+x: close tab"))
+	return doc.String()
+}
+
+func getErrorView(renderer *lipgloss.Renderer, name string, message string) (Tab) {
+	headerStyle := renderer.NewStyle().
+		Foreground(lipgloss.Color("#cc0000")).
+		Bold(true)
+	quitStyle := renderer.NewStyle().Foreground(lipgloss.Color("8"))
+
+	return Tab{
+		Model: ErrorModel{
+			headerStyle: headerStyle,
+			quitStyle: quitStyle,
+			message: message,
+		},
+		Name: name,
+	}
+}
+
+type ErrorModel struct {
+	headerStyle  lipgloss.Style
+	quitStyle    lipgloss.Style
+	message      string
+}
+
+func (m ErrorModel) Init() tea.Cmd {
+	return nil
+}
+
+func (m ErrorModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	return m, nil
+}
+
+func (m ErrorModel) View() string {
+	doc := strings.Builder{}
+	doc.WriteString(m.headerStyle.Render("Something went wrong"))
+	doc.WriteString("\n")
+	doc.WriteString(m.message)
+	doc.WriteString("\n\n")
+	doc.WriteString(m.quitStyle.Render("alt+x: close tab • try again later"))
+	return doc.String()
+}
+
+func CreateErrorBanner(renderer *lipgloss.Renderer) ErrorBanner {
+	style := renderer.NewStyle().
+		Foreground(lipgloss.Color("#cc0000")).
+		MaxHeight(1)
+
+	infoStyle := renderer.NewStyle().
+		Foreground(lipgloss.Color("8")).
+		MaxHeight(1)
+
+	return ErrorBanner{
+		style: style,
+		infoStyle: infoStyle,
+	}
+}
+
+// ErrorBanner is a single line shown above a tab's content
+// when one of its database calls fails.
+type ErrorBanner struct {
+	message      string
+	info         bool
+	style        lipgloss.Style
+	infoStyle    lipgloss.Style
+}
+
+// Show displays a specific message for expected errors,
+// like liking a post twice, and the given one otherwise.
+func (b *ErrorBanner) Show(message string, err error) {
+	if IsDomainError(err) {
+		log.Warn(message, "error", err)
+	} else {
+		log.Error(message, "error", err)
+	}
+	b.message = ErrorMessage(err, message)
+	b.info = false
+}
+
+// Info shows a confirmation of an action that has no other
+// visible outcome, in the place of errors.
+func (b *ErrorBanner) Info(message string) {
+	b.message = message
+	b.info = true
+}
+
+func (b *ErrorBanner) Clear() {
+	b.message = ""
+	b.info = false
+}
+
+func (b ErrorBanner) Height() int {
+	if b.message == "" {
+		return 0
+	}
+	return 1
+}
+
+func (b ErrorBanner) View() string {
+	if b.message == "" {
+		return ""
+	}
+	if b.info {
+		return b.infoStyle.Render(b.message)
+	}
+	return b.style.Render("⚠ " + b.message)
+}
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/lib/pq"
+)
+
+var (
+	ErrNotFound         = errors.New("not found")
+	ErrAlreadyLiked     = errors.New("already liked")
+	ErrNotLiked         = errors.New("not liked")
+	ErrAlreadyFollowing = errors.New("already following")
+	ErrNotFollowing     = errors.New("not following")
+	ErrAlreadyVoted     = errors.New("already voted")
+	ErrAlreadyReported  = errors.New("already reported")
+	ErrPollClosed       = errors.New("poll closed")
+	ErrUsernameTaken    = errors.New("username taken")
+	ErrKeyTaken         = errors.New("key already registered")
+	ErrTimeout          = errors.New("database timeout")
+	ErrReadOnly         = errors.New("read-only mode")
+	ErrRateLimited      = errors.New("rate limited")
+)
+
+// constraintErrors maps unique constraints to the error
+// reported when an insert violates them.
+var constraintErrors = map[string]error{
+	"unique_like":        ErrAlreadyLiked,
+	"unique_follow":      ErrAlreadyFollowing,
+	"unique_vote":        ErrAlreadyVoted,
+	"unique_report":      ErrAlreadyReported,
+	"users_username_key": ErrUsernameTaken,
+	"users_key_key":      ErrKeyTaken,
+}
+
+// raisedErrors maps messages of exceptions raised in our
+// procedures with ERRCODE P0001.
+var raisedErrors = map[string]error{
+	"Not liking":     ErrNotLiked,
+	"Not following":  ErrNotFollowing,
+	"Poll closed":    ErrPollClosed,
+	"Unknown option": ErrNotFound,
+}
+
+// mapDbError translates driver errors into the errors above,
+// keeping the original one wrapped for logs.
+func mapDbError(err error) error {
+	if err == nil || IsDomainError(err) || errors.Is(err, ErrTimeout) {
+		return err
+	}
+	var domain error
+	var pqErr *pq.Error
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		domain = ErrNotFound
+	case errors.Is(err, context.DeadlineExceeded):
+		domain = ErrTimeout
+	case errors.As(err, &pqErr):
+		switch pqErr.Code {
+		case "23505":
+			domain = constraintErrors[pqErr.Constraint]
+		case "23503":
+			domain = ErrNotFound
+		case "P0001":
+			domain = raisedErrors[pqErr.Message]
+		case "57014":
+			domain = ErrTimeout
+		}
+	}
+	if domain == nil {
+		return err
+	}
+	return fmt.Errorf("%w: %w", domain, err)
+}
+
+// errorMessages are shown to the user instead of the generic
+// message of the failed action.
+var errorMessages = []struct {
+	err     error
+	message string
+}{
+	{ErrNotFound, "It doesn't exist anymore"},
+	{ErrAlreadyLiked, "You already like this post"},
+	{ErrNotLiked, "You don't like this post yet"},
+	{ErrAlreadyFollowing, "You already follow this user"},
+	{ErrNotFollowing, "You don't follow this user"},
+	{ErrAlreadyVoted, "You already voted in this poll"},
+	{ErrAlreadyReported, "You already reported this post"},
+	{ErrPollClosed, "This poll is closed"},
+	{ErrUsernameTaken, "This username is taken"},
+	{ErrKeyTaken, "This key is already registered"},
+	{ErrTimeout, "The database is not responding, try again later"},
+	{ErrReadOnly, "Read-only mode: the server is under maintenance"},
+	{ErrRateLimited, "You're doing that too often, try again in a moment"},
+}
+
+// ErrorMessage describes err for the user, falling back to
+// message for unexpected failures.
+func ErrorMessage(err error, message string) string {
+	for _, e := range errorMessages {
+		if errors.Is(err, e.err) {
+			return e.message
+		}
+	}
+	return message
+}
+
+// IsDomainError tells expected outcomes, like liking a post
+// twice, from real failures.
+func IsDomainError(err error) bool {
+	for _, e := range errorMessages {
+		if errors.Is(err, e.err) && !errors.Is(err, ErrTimeout) {
+			return true
+		}
+	}
+	return false
+}
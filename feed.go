@@ -1,19 +1,18 @@
 package main
 
 import (
+	"context"
 	"database/sql"
-	"time"
+	"errors"
 
-	"github.com/charmbracelet/bubbles/textarea"
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/charmbracelet/lipgloss"
-	"github.com/charmbracelet/log"
 	"github.com/charmbracelet/bubbles/viewport"
 )
 
-type FindPostsFunc func(db *sql.DB, viewer SavedUser) ([]Post, error)
+type FindPostsFunc func(ctx context.Context, db *sql.DB, viewer SavedUser) ([]Post, error)
 
-func getFeedView(renderer *lipgloss.Renderer, db *sql.DB, user SavedUser, find FindPostsFunc, name string) (Tab) {
+func getFeedView(parent context.Context, renderer *lipgloss.Renderer, db *sql.DB, user SavedUser, find FindPostsFunc, name string) (Tab) {
 	infoWidth := 20
 	infoStyle := renderer.NewStyle().
 		MaxWidth(infoWidth).
@@ -34,26 +33,23 @@ func getFeedView(renderer *lipgloss.Renderer, db *sql.DB, user SavedUser, find F
 	numberStyle := quitStyle.
 		Bold(true)
 
-	posts, err := find(db, user)
-	if err != nil {
-		log.Error(err)
-	}
-	timeline := getTimeline(renderer, db, posts, user)
-
-	textInput := textarea.New()
-	textInput.Placeholder = "Type a message..."
+	timeline := getTimeline(renderer, db, nil, user)
 
-	textInput.CharLimit = 280
-	textInput.SetWidth(30)
-	textInput.SetHeight(3)
-	textInput.FocusedStyle.CursorLine = lipgloss.NewStyle()
-	textInput.ShowLineNumbers = false
+	composer := CreateComposer(renderer)
 
 	newViewport := viewport.New(20, 15)
 
+	ctx, cancel := context.WithCancel(parent)
+
+	loader := CreateLoader(renderer)
+	loader.Start("Loading posts...")
+
 	return Tab {
-		Model: FeedModel{ 
-			infoStyle: infoStyle, 
+		Model: FeedModel{
+			id: nextTabId(),
+			ctx: ctx,
+			cancel: cancel,
+			infoStyle: infoStyle,
 			quitStyle: quitStyle,
 			postStyle: postStyle,
 			headerStyle: headerStyle,
@@ -62,10 +58,12 @@ func getFeedView(renderer *lipgloss.Renderer, db *sql.DB, user SavedUser, find F
 			renderer: renderer,
 			posts: timeline,
 			user: user,
-			text: textInput,
+			composer: composer,
 			inputOpened: false,
 			viewport: newViewport,
 			find: find,
+			banner: CreateErrorBanner(renderer),
+			loader: loader,
 		},
 		Name: name,
 	}
@@ -74,6 +72,9 @@ func getFeedView(renderer *lipgloss.Renderer, db *sql.DB, user SavedUser, find F
 
 type FeedModel struct {
 	Name         string
+	id           int64
+	ctx          context.Context
+	cancel       context.CancelFunc
 	infoStyle    lipgloss.Style
 	quitStyle    lipgloss.Style
 	postStyle    lipgloss.Style
@@ -84,77 +85,165 @@ type FeedModel struct {
 	db           *sql.DB
 	renderer     *lipgloss.Renderer
 	width        int
-	text         textarea.Model
+	height       int
+	composer     Composer
 	inputOpened  bool
+	publishing   bool
 	viewport     viewport.Model
 	find         FindPostsFunc
+	banner       ErrorBanner
+	loader       Loader
 }
 
 func (m FeedModel) Init() tea.Cmd {
-	return nil
+	return tea.Batch(
+		m.loader.Tick(),
+		loadPosts(m.ctx, m.id, m.db, m.user, m.find),
+	)
+}
+
+func (m FeedModel) Close() {
+	m.cancel()
 }
 
 func (m FeedModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	switch msg := msg.(type) {
-	case tea.WindowSizeMsg: 
+	case tea.WindowSizeMsg:
 		m.width = msg.Width
+		m.height = msg.Height
 		m.posts.width = max(m.width, 20) - 2
 		m.viewport.Width = m.posts.width
-		m.viewport.Height = msg.Height - 5
+		m.layout()
+		m.viewport.SetContent(m.posts.View())
+		return m, nil
+	case PostsLoadedMsg:
+		if msg.tab != m.id {
+			return m, nil
+		}
+		m.loader.Done()
+		if msg.err != nil {
+			m.banner.Show("Could not load posts", msg.err)
+		} else {
+			m.banner.Clear()
+			m.posts = getTimeline(m.renderer, m.db, msg.posts, m.user)
+			m.posts.width = max(m.width, 20) - 2
+		}
+		m.layout()
+		m.viewport.SetContent(m.posts.View())
+		return m, nil
+	case ReportMsg:
+		if msg.tab != m.id {
+			return m, nil
+		}
+		m.loader.Done()
+		if msg.err != nil {
+			m.banner.Show("Could not report the post", msg.err)
+		} else {
+			m.banner.Info("Reported, thank you. Moderators will take a look.")
+		}
+		m.layout()
+		return m, nil
+	case PostSavedMsg:
+		if msg.tab != m.id {
+			return m, nil
+		}
+		m.loader.Done()
+		m.publishing = false
+		if msg.err != nil {
+			// The composer keeps the post, so it can be sent again.
+			m.banner.Show("Could not publish your post", msg.err)
+			m.layout()
+			return m, m.composer.Focus()
+		}
+		m.closeComposer()
+		m.posts.Push(msg.post)
 		m.viewport.SetContent(m.posts.View())
+		m.viewport.GotoTop()
+		m.layout()
+		return m, nil
+	case LikeMsg:
+		if msg.tab != m.id {
+			return m, nil
+		}
+		m.loader.Done()
+		if errors.Is(msg.err, ErrAlreadyLiked) || errors.Is(msg.err, ErrNotLiked) {
+			// the like was changed in another session
+			m.banner.Show("Could not update the like", msg.err)
+			m.posts.ApplyLike(msg.postId, msg.liked)
+			m.viewport.SetContent(m.posts.View())
+		} else if msg.err != nil {
+			m.banner.Show("Could not update the like", msg.err)
+		} else {
+			m.posts.ApplyLike(msg.postId, msg.liked)
+			m.viewport.SetContent(m.posts.View())
+		}
+		m.layout()
+		return m, nil
+	case VoteMsg:
+		if msg.tab != m.id {
+			return m, nil
+		}
+		m.loader.Done()
+		if msg.err != nil {
+			m.banner.Show("Could not save your vote", msg.err)
+		} else {
+			m.posts.ApplyVote(msg.postId, msg.option)
+			m.viewport.SetContent(m.posts.View())
+		}
+		m.layout()
 		return m, nil
 	case tea.KeyMsg:
-		if m.text.Focused() {
+		if m.publishing {
+			return m, nil
+		}
+		if m.inputOpened {
 			switch msg.String() {
 			case "esc":
-				m.text.Blur()
-				m.inputOpened = false
-				m.viewport.Height = m.viewport.Height + 4
+				m.closeComposer()
 				return m, nil
 			case "enter":
-				m.text.Blur()
-				m.inputOpened = false
-				m.viewport.Height = m.viewport.Height + 4
-				text := m.text.Value()
-				m.text.Reset()
-				if (text == "") { 
+				valid := m.composer.Validate()
+				m.layout()
+				if !valid {
 					return m, nil
 				}
-				id, err := SavePost(m.db, m.user, text) 
-				if err == nil {
-					m.posts.Push(Post {
-						id: id, 
-						userId: m.user.id, 
-						content: text, 
-						username: m.user.username, 
-						createdAt: time.Now(),
-					})
-					m.viewport.SetContent(m.posts.View())
-					m.viewport.GotoTop()
-				} else {
-					log.Error(err)
+				text := m.composer.Value()
+				if (text == "") {
+					m.closeComposer()
+					return m, nil
 				}
-
-				return m, nil
+				cmd := savePost(m.ctx, m.id, m.db, m.user, m.composer)
+				m.publishing = true
+				m.composer.Blur()
+				cmd = tea.Batch(m.loader.Start("Publishing..."), cmd)
+				m.layout()
+				return m, cmd
 			default:
 				var cmd tea.Cmd
-				m.text, cmd = m.text.Update(msg)
+				m.composer, cmd = m.composer.Update(msg)
+				m.layout()
 				return m, cmd
 			}
 		} else {
+			switch msg.String() {
+			case "p", "1", "2", "3", "4", "l", "!":
+				if refuseWrite(&m.banner) {
+					m.layout()
+					return m, nil
+				}
+			}
 			switch msg.String() {
 			case "p":
 				m.inputOpened = true
-				m.viewport.Height = m.viewport.Height - 4
-				return m, m.text.Focus()
+				m.layout()
+				return m, m.composer.Focus()
 			case "r":
-				posts, err := m.find(m.db, m.user)
-				if err != nil {
-					log.Error(err)
-				}
-				m.posts = getTimeline(m.renderer, m.db, posts, m.user)
-				m.viewport.SetContent(m.posts.View())
-				return m, nil
+				cmd := tea.Batch(
+					m.loader.Start("Loading posts..."),
+					loadPosts(m.ctx, m.id, m.db, m.user, m.find),
+				)
+				m.layout()
+				return m, cmd
 			case "k", "j":
 				m.posts, m.viewport = UpdateTimeline(m.posts, m.viewport, msg)
 				return m, nil
@@ -162,43 +251,61 @@ func (m FeedModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 				var cmd tea.Cmd
 				m.posts, cmd = m.posts.Update(msg)
 				return m, cmd
+			case "e":
+				m.posts.ToggleWarning(m.posts.currentPost)
+				m.viewport.SetContent(m.posts.View())
+				return m, nil
+			case "1", "2", "3", "4":
+				option := int(msg.String()[0] - '1')
+				post, ok := m.posts.Votable(option)
+				if !ok {
+					return m, nil
+				}
+				cmd := tea.Batch(m.loader.Start("Voting..."), vote(m.ctx, m.id, m.db, m.user, post, option))
+				m.layout()
+				return m, cmd
 			case "l":
-				var err error
-				post := m.posts.posts[m.posts.currentPost]
-				if post.liked {
-					err = DeleteLike(m.db, m.user, post)
-				} else {
-					err = SaveLike(m.db, m.user, post)
+				post, ok := m.posts.Current()
+				if !ok {
+					return m, nil
 				}
-				
-				if err == nil {
-					m.posts.posts[m.posts.currentPost].liked = !post.liked
-					if post.liked {
-						m.posts.posts[m.posts.currentPost].likes = post.likes - 1 
-					} else {
-						m.posts.posts[m.posts.currentPost].likes = post.likes + 1 
-					}
-					m.viewport.SetContent(m.posts.View())
+				cmd := tea.Batch(m.loader.Start("Saving like..."), toggleLike(m.ctx, m.id, m.db, m.user, post))
+				m.layout()
+				return m, cmd
+			case "!":
+				post, ok := m.posts.Current()
+				if !ok {
+					return m, nil
 				}
-				return m, nil
+				cmd := tea.Batch(m.loader.Start("Reporting..."), reportPost(m.ctx, m.id, m.db, m.user, post))
+				m.layout()
+				return m, cmd
 			}
 			var cmd tea.Cmd
 			m.viewport, cmd = m.viewport.Update(msg)
 			return m, cmd
 		}
 	}
-	return m, nil
+	var cmd tea.Cmd
+	m.loader, cmd = m.loader.Update(msg)
+	return m, cmd
 }
 
 func (m FeedModel) View() string {
 	postsWidth := max(m.width, 20)
 	posts := make([]string, 0)
+	if m.loader.Height() > 0 {
+		posts = append(posts, m.loader.View())
+	}
+	if m.banner.Height() > 0 {
+		posts = append(posts, m.banner.View())
+	}
 	if m.inputOpened {
-		posts = append(posts, m.text.View() + "\n")
+		posts = append(posts, m.composer.View())
 	}
 	posts = append(posts, m.viewport.View())
 	renderedPosts := lipgloss.JoinVertical(lipgloss.Top, posts...)
-	
+
 	postList := m.postStyle.
 	        Width(postsWidth).
 	        MaxWidth(postsWidth).
@@ -206,3 +313,35 @@ func (m FeedModel) View() string {
 	return postList
 }
 
+// layout gives the viewport whatever height is left after
+// the loader, the error banner and the composer.
+func (m *FeedModel) layout() {
+	height := m.height - 5 - m.loader.Height() - m.banner.Height()
+	if m.inputOpened {
+		height -= m.composer.Height()
+	}
+	m.viewport.Height = max(height, 0)
+}
+
+func (m FeedModel) Draft() (Draft, bool) {
+	if !m.inputOpened || m.composer.Value() == "" {
+		return Draft{}, false
+	}
+	return Draft{content: m.composer.Value(), contentWarning: m.composer.ContentWarning()}, true
+}
+
+// OpenDraft opens the composer with a draft saved when the
+// server restarted.
+func (m FeedModel) OpenDraft(draft Draft) (FeedModel, tea.Cmd) {
+	m.composer.Reset()
+	m.composer.SetDraft(draft)
+	m.inputOpened = true
+	m.layout()
+	return m, m.composer.Focus()
+}
+
+func (m *FeedModel) closeComposer() {
+	m.inputOpened = false
+	m.composer.Reset()
+	m.layout()
+}
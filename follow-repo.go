@@ -1,12 +1,12 @@
 package main
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
 	"time"
 
 	"github.com/charmbracelet/log"
-	"github.com/lib/pq"
 )
 
 type Follow struct {
@@ -56,24 +56,29 @@ func CreateFollowFunction(db *sql.DB) {
 	log.Info("Procedure created successfully!")
 }
 
-func SaveFollow(db *sql.DB, user SavedUser, followed SavedUser) error {
+func SaveFollow(ctx context.Context, db *sql.DB, user SavedUser, followed SavedUser) error {
+	if err := checkWritable(); err != nil {
+		return err
+	}
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
 	log.Info("Saving follow to db")
 	query := `CALL add_follow($1, $2)`
 
-	_, err := db.Exec(query, user.id, followed.id)
+	_, err := db.ExecContext(ctx, query, user.id, followed.id)
 
 	if err != nil {
-		if pqErr, ok := err.(*pq.Error); ok {
-			if pqErr.Code == "23505" {
-				log.Warnf("Already followed: %v", err)
-				return fmt.Errorf("Already followed: %v", err)
-			}
+		err = mapDbError(err)
+		if IsDomainError(err) {
+			log.Warn(err)
+			return err
 		}
 		log.Errorf("failed to insert follow: %v", err)
-		return fmt.Errorf("failed to insert follow: %v", err)
+		return fmt.Errorf("failed to insert follow: %w", mapDbError(err))
 	}
 
 	log.Info("Saved new follow")
+	followsCreated.Inc()
 	return nil
 }
 
@@ -111,40 +116,46 @@ func CreateUnfollowFunction(db *sql.DB) {
 	log.Info("Procedure created successfully!")
 }
 
-func DeleteFollow(db *sql.DB, user SavedUser, followed SavedUser) error {
+func DeleteFollow(ctx context.Context, db *sql.DB, user SavedUser, followed SavedUser) error {
+	if err := checkWritable(); err != nil {
+		return err
+	}
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
 	log.Info("Deleting follow in db")
 	query := `CALL delete_follow($1, $2)`
 
-	_, err := db.Exec(query, user.id, followed.id)
+	_, err := db.ExecContext(ctx, query, user.id, followed.id)
 
 	if err != nil {
-		if pqErr, ok := err.(*pq.Error); ok {
-			if pqErr.Code == "P0001" {
-				log.Warnf("Not followed: %v", err)
-				return fmt.Errorf("Not followed: %v", err)
-			}
+		err = mapDbError(err)
+		if IsDomainError(err) {
+			log.Warn(err)
+			return err
 		}
 		log.Errorf("failed to delete follow: %v", err)
-		return  fmt.Errorf("failed to delete follow: %v", err)
+		return  fmt.Errorf("failed to delete follow: %w", mapDbError(err))
 	}
 
 	log.Info("Deleted follow")
 	return nil
 }
 
-func CheckFollow(db *sql.DB, user SavedUser, other SavedUser) (bool, error) {
+func CheckFollow(ctx context.Context, db *sql.DB, user SavedUser, other SavedUser) (bool, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
 	var exists bool;
 	query := `
 	SELECT EXISTS (
 	    SELECT 1 FROM follows WHERE user_id = $1 AND followed_id = $2
 	);`
 
-	err := db.QueryRow(query, user.id, other.id).
+	err := db.QueryRowContext(ctx, query, user.id, other.id).
 		Scan(&exists)
 
 	if err != nil {
 		log.Errorf("Error while fetching follows: %v", err)
-		return  false, fmt.Errorf("Error while fetching follows: %v", err)
+		return  false, fmt.Errorf("Error while fetching follows: %w", mapDbError(err))
 	}
 
 	return exists, nil
@@ -0,0 +1,188 @@
+package main
+
+import (
+	"net/url"
+	"strings"
+	"unicode"
+
+	"github.com/charmbracelet/lipgloss"
+	"github.com/charmbracelet/x/ansi"
+	"github.com/muesli/termenv"
+)
+
+type Formatter struct {
+	boldStyle        lipgloss.Style
+	italicStyle      lipgloss.Style
+	codeStyle        lipgloss.Style
+	blockStyle       lipgloss.Style
+	linkStyle        lipgloss.Style
+	hyperlinks       bool
+}
+
+func getFormatter(renderer *lipgloss.Renderer) Formatter {
+	return Formatter{
+		boldStyle: renderer.NewStyle().Bold(true),
+		italicStyle: renderer.NewStyle().Italic(true),
+		codeStyle: renderer.NewStyle().Foreground(lipgloss.Color("3")),
+		blockStyle: renderer.NewStyle().
+			Foreground(lipgloss.Color("3")).
+			BorderLeft(true).
+			BorderStyle(lipgloss.ThickBorder()).
+			BorderForeground(lipgloss.Color("8")).
+			PaddingLeft(1),
+		linkStyle: renderer.NewStyle().
+			Foreground(lipgloss.Color("#1da1f2")),
+		// There is no reliable way to ask a terminal about OSC 8 over SSH;
+		// clients reporting true color are recent enough to handle it
+		// or at least to ignore the sequence.
+		hyperlinks: renderer.ColorProfile() == termenv.TrueColor,
+	}
+}
+
+// Render formats a post body: **bold**, *italics*, `code`, fenced
+// code blocks and links. Escape sequences in the content are stripped
+// before any styling is applied.
+func (f Formatter) Render(content string) string {
+	content = Sanitize(content)
+	lines := strings.Split(content, "\n")
+	output := make([]string, 0, len(lines))
+	block := make([]string, 0)
+	inBlock := false
+
+	for _, line := range lines {
+		if strings.HasPrefix(strings.TrimSpace(line), "```") {
+			if inBlock {
+				output = append(output, f.blockStyle.Render(strings.Join(block, "\n")))
+				block = block[:0]
+			}
+			inBlock = !inBlock
+			continue
+		}
+		if inBlock {
+			block = append(block, line)
+		} else {
+			output = append(output, f.renderInline(line))
+		}
+	}
+	if inBlock {
+		output = append(output, f.blockStyle.Render(strings.Join(block, "\n")))
+	}
+	return strings.Join(output, "\n")
+}
+
+func (f Formatter) renderInline(line string) string {
+	doc := strings.Builder{}
+	runes := []rune(line)
+	for i := 0; i < len(runes); i++ {
+		rest := string(runes[i:])
+		switch {
+		case runes[i] == '`':
+			if end := indexFrom(runes, i+1, "`"); end > i+1 {
+				doc.WriteString(f.codeStyle.Render(string(runes[i+1 : end])))
+				i = end
+				continue
+			}
+		case strings.HasPrefix(rest, "**"):
+			if end := indexFrom(runes, i+2, "**"); end > i+2 {
+				doc.WriteString(f.boldStyle.Render(string(runes[i+2 : end])))
+				i = end + 1
+				continue
+			}
+		case (runes[i] == '*' || runes[i] == '_') && opensEmphasis(runes, i):
+			if end := indexFrom(runes, i+1, string(runes[i])); end > i+1 && closesEmphasis(runes, end) {
+				doc.WriteString(f.italicStyle.Render(string(runes[i+1 : end])))
+				i = end
+				continue
+			}
+		case runes[i] == '[':
+			if text, link, length, ok := parseLink(runes[i:]); ok {
+				doc.WriteString(f.link(text, link))
+				i += length - 1
+				continue
+			}
+		case strings.HasPrefix(rest, "http://") || strings.HasPrefix(rest, "https://"):
+			if i == 0 || !isWordRune(runes[i-1]) {
+				end := i
+				for end < len(runes) && !unicode.IsSpace(runes[end]) {
+					end++
+				}
+				link := strings.TrimRight(string(runes[i:end]), ".,;:!?)")
+				if safeLink(link) {
+					doc.WriteString(f.link(link, link))
+					i += len([]rune(link)) - 1
+					continue
+				}
+			}
+		}
+		doc.WriteRune(runes[i])
+	}
+	return doc.String()
+}
+
+func (f Formatter) link(text string, link string) string {
+	if f.hyperlinks {
+		return ansi.SetHyperlink(link) + f.linkStyle.Render(text) + ansi.ResetHyperlink()
+	}
+	if text == link {
+		return f.linkStyle.Render(text)
+	}
+	return f.linkStyle.Render(text) + " (" + link + ")"
+}
+
+// parseLink reads a [text](url) link at the start of runes and
+// returns its parts together with the number of runes consumed.
+func parseLink(runes []rune) (string, string, int, bool) {
+	closeText := indexFrom(runes, 1, "](")
+	if closeText < 2 {
+		return "", "", 0, false
+	}
+	closeLink := indexFrom(runes, closeText+2, ")")
+	if closeLink < 0 {
+		return "", "", 0, false
+	}
+	text := string(runes[1:closeText])
+	link := string(runes[closeText+2 : closeLink])
+	if !safeLink(link) {
+		return "", "", 0, false
+	}
+	return text, link, closeLink + 1, true
+}
+
+func safeLink(link string) bool {
+	parsed, err := url.Parse(link)
+	if err != nil || parsed.Host == "" {
+		return false
+	}
+	return parsed.Scheme == "http" || parsed.Scheme == "https"
+}
+
+func indexFrom(runes []rune, start int, sep string) int {
+	if start > len(runes) {
+		return -1
+	}
+	index := strings.Index(string(runes[start:]), sep)
+	if index < 0 {
+		return -1
+	}
+	return start + len([]rune(string(runes[start:])[:index]))
+}
+
+func isWordRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r)
+}
+
+// opensEmphasis keeps snake_case identifiers and lone asterisks
+// from being treated as italics.
+func opensEmphasis(runes []rune, i int) bool {
+	if i > 0 && isWordRune(runes[i-1]) {
+		return false
+	}
+	return i+1 < len(runes) && !unicode.IsSpace(runes[i+1])
+}
+
+func closesEmphasis(runes []rune, i int) bool {
+	if unicode.IsSpace(runes[i-1]) {
+		return false
+	}
+	return i+1 == len(runes) || !isWordRune(runes[i+1])
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/charmbracelet/log"
+)
+
+// HealthHandler answers /healthz while the process runs and
+// /readyz while it can take new sessions: the database answers,
+// the SSH listener accepts connections and no shutdown started.
+type HealthHandler struct {
+	db      *sql.DB
+	sshAddr string
+}
+
+func NewHealthHandler(db *sql.DB, sshAddr string) HealthHandler {
+	return HealthHandler{db: db, sshAddr: sshAddr}
+}
+
+func (h HealthHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Cache-Control", "no-store")
+	switch r.URL.Path {
+	case "/healthz":
+		writeJSON(w, r, http.StatusOK, map[string]string{"status": "ok"})
+	case "/readyz":
+		checks := h.checkReadiness(r.Context())
+		status := http.StatusOK
+		for _, result := range checks {
+			if result != "ok" {
+				status = http.StatusServiceUnavailable
+			}
+		}
+		writeJSON(w, r, status, checks)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func (h HealthHandler) checkReadiness(ctx context.Context) map[string]string {
+	checks := map[string]string{"database": "ok", "ssh": "ok", "draining": "ok"}
+	ctx, cancel := context.WithTimeout(ctx, 2*time.Second)
+	defer cancel()
+	if err := h.db.PingContext(ctx); err != nil {
+		log.Warn("Readiness check failed", "check", "database", "error", err)
+		checks["database"] = err.Error()
+	}
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "tcp", h.sshAddr)
+	if err != nil {
+		log.Warn("Readiness check failed", "check", "ssh", "error", err)
+		checks["ssh"] = err.Error()
+	} else {
+		conn.Close()
+	}
+	if draining.Load() {
+		checks["draining"] = "shutting down"
+	}
+	return checks
+}
@@ -0,0 +1,364 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/charmbracelet/log"
+)
+
+func CreateHomeTimelineTable(db *sql.DB) {
+	createTableSQL := `
+	CREATE TABLE IF NOT EXISTS home_timeline (
+		user_id INTEGER REFERENCES users(id) ON DELETE CASCADE,
+		post_id INTEGER REFERENCES posts(id) ON DELETE CASCADE,
+		author_id INTEGER REFERENCES users(id) ON DELETE CASCADE,
+		created_at TIMESTAMP WITH TIME ZONE NOT NULL,
+		PRIMARY KEY (user_id, post_id)
+	);
+	CREATE INDEX IF NOT EXISTS home_timeline_user_created
+		ON home_timeline (user_id, created_at DESC);`
+
+	_, err := db.Exec(createTableSQL)
+	if err != nil {
+		log.Fatalf("Failed to create table: %v", err)
+	}
+
+	log.Info("Table 'home_timeline' created successfully!")
+}
+
+func FanOutPost(ctx context.Context, db *sql.DB, postId int64, size int) error {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		log.Errorf("failed to start transaction: %v", err)
+		return fmt.Errorf("failed to start transaction: %w", mapDbError(err))
+	}
+	defer tx.Rollback()
+
+	query := `
+	INSERT INTO home_timeline (user_id, post_id, author_id, created_at)
+	SELECT f.user_id, p.id, p.user_id, p.created_at
+	FROM posts p
+	INNER JOIN follows f ON f.followed_id = p.user_id
+	WHERE p.id = $1
+	ON CONFLICT DO NOTHING`
+	_, err = tx.ExecContext(ctx, query, postId)
+	if err != nil {
+		log.Errorf("failed to fan out post: %v", err)
+		return fmt.Errorf("failed to fan out post: %w", mapDbError(err))
+	}
+
+	query = `
+	DELETE FROM home_timeline h
+	USING (
+		SELECT user_id, post_id,
+		       ROW_NUMBER() OVER (PARTITION BY user_id ORDER BY created_at DESC) AS position
+		FROM home_timeline
+		WHERE user_id IN (
+			SELECT f.user_id FROM follows f
+			INNER JOIN posts p ON f.followed_id = p.user_id
+			WHERE p.id = $1
+		)
+	) ranked
+	WHERE h.user_id = ranked.user_id
+	AND h.post_id = ranked.post_id
+	AND ranked.position > $2`
+	_, err = tx.ExecContext(ctx, query, postId, size)
+	if err != nil {
+		log.Errorf("failed to trim timelines: %v", err)
+		return fmt.Errorf("failed to trim timelines: %w", mapDbError(err))
+	}
+
+	if err = tx.Commit(); err != nil {
+		log.Errorf("failed to commit fan out: %v", err)
+		return fmt.Errorf("failed to commit fan out: %w", mapDbError(err))
+	}
+	return nil
+}
+
+func BackfillHomeTimeline(ctx context.Context, db *sql.DB, userId int64, followedId int64, size int) error {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		log.Errorf("failed to start transaction: %v", err)
+		return fmt.Errorf("failed to start transaction: %w", mapDbError(err))
+	}
+	defer tx.Rollback()
+
+	query := `
+	INSERT INTO home_timeline (user_id, post_id, author_id, created_at)
+	SELECT $1, p.id, p.user_id, p.created_at
+	FROM posts p
+	WHERE p.user_id = $2
+	ORDER BY p.created_at DESC
+	LIMIT $3
+	ON CONFLICT DO NOTHING`
+	_, err = tx.ExecContext(ctx, query, userId, followedId, size)
+	if err != nil {
+		log.Errorf("failed to backfill timeline: %v", err)
+		return fmt.Errorf("failed to backfill timeline: %w", mapDbError(err))
+	}
+
+	query = `
+	DELETE FROM home_timeline h
+	USING (
+		SELECT post_id,
+		       ROW_NUMBER() OVER (ORDER BY created_at DESC) AS position
+		FROM home_timeline
+		WHERE user_id = $1
+	) ranked
+	WHERE h.user_id = $1
+	AND h.post_id = ranked.post_id
+	AND ranked.position > $2`
+	_, err = tx.ExecContext(ctx, query, userId, size)
+	if err != nil {
+		log.Errorf("failed to trim timeline: %v", err)
+		return fmt.Errorf("failed to trim timeline: %w", mapDbError(err))
+	}
+
+	if err = tx.Commit(); err != nil {
+		log.Errorf("failed to commit backfill: %v", err)
+		return fmt.Errorf("failed to commit backfill: %w", mapDbError(err))
+	}
+	return nil
+}
+
+func RemoveFromHomeTimeline(ctx context.Context, db *sql.DB, userId int64, followedId int64) error {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+	query := `DELETE FROM home_timeline WHERE user_id = $1 AND author_id = $2`
+	_, err := db.ExecContext(ctx, query, userId, followedId)
+	if err != nil {
+		log.Errorf("failed to clean timeline: %v", err)
+		return fmt.Errorf("failed to clean timeline: %w", mapDbError(err))
+	}
+	return nil
+}
+
+func FindHomeTimelinePosts(ctx context.Context, db *sql.DB, viewer SavedUser) ([]Post, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+	query := `
+	SELECT p.id, p.content, p.content_warning, p.user_id, p.created_at, u.username, p.likes, p.replies,
+	       CASE WHEN l.user_id IS NOT NULL THEN TRUE ELSE FALSE END AS liked
+	FROM home_timeline h
+	INNER JOIN posts p ON p.id = h.post_id
+	LEFT JOIN likes l ON p.id = l.post_id AND l.user_id = $1
+	LEFT JOIN users u ON p.user_id = u.id
+	WHERE h.user_id = $1
+	ORDER BY h.created_at DESC`
+	rows, err := db.QueryContext(ctx, query, viewer.id)
+	if err != nil {
+		return nil, mapDbError(err)
+	}
+	defer rows.Close()
+
+	var posts []Post
+	for rows.Next() {
+		var post Post
+		if err := rows.Scan(&post.id, &post.content, &post.contentWarning, &post.userId, &post.createdAt, &post.username, &post.likes, &post.replies, &post.liked); err != nil {
+			return nil, mapDbError(err)
+		}
+		posts = append(posts, post)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, mapDbError(err)
+	}
+
+	return posts, nil
+}
+
+func CreateHomeTimelineJobTable(db *sql.DB) {
+	createTableSQL := `
+	CREATE TABLE IF NOT EXISTS home_timeline_jobs (
+		id SERIAL PRIMARY KEY,
+		kind VARCHAR(20) NOT NULL,
+		post_id INTEGER,
+		user_id INTEGER,
+		followed_id INTEGER,
+		attempts INTEGER NOT NULL DEFAULT 0,
+		claimed_until TIMESTAMP WITH TIME ZONE,
+		created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP
+	);`
+
+	_, err := db.Exec(createTableSQL)
+	if err != nil {
+		log.Fatalf("Failed to create table: %v", err)
+	}
+
+	log.Info("Table 'home_timeline_jobs' created successfully!")
+}
+
+func EnqueueTimelineJob(ctx context.Context, db *sql.DB, job timelineJob) error {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+	query := `
+	INSERT INTO home_timeline_jobs (kind, post_id, user_id, followed_id)
+	VALUES ($1, NULLIF($2, 0), NULLIF($3, 0), NULLIF($4, 0))`
+	_, err := db.ExecContext(ctx, query, string(job.kind), job.postId, job.userId, job.followedId)
+	if err != nil {
+		log.Errorf("failed to enqueue timeline job: %v", err)
+		return fmt.Errorf("failed to enqueue timeline job: %w", mapDbError(err))
+	}
+	return nil
+}
+
+// ClaimTimelineJobs takes the oldest jobs nobody works on. A job
+// that isn't finished before the lease ends is claimed again.
+func ClaimTimelineJobs(ctx context.Context, db *sql.DB, limit int, lease time.Duration) ([]timelineJob, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+	query := `
+	UPDATE home_timeline_jobs
+	SET attempts = attempts + 1,
+	    claimed_until = NOW() + make_interval(secs => $2)
+	WHERE id IN (
+		SELECT id FROM home_timeline_jobs
+		WHERE claimed_until IS NULL OR claimed_until <= NOW()
+		ORDER BY id
+		LIMIT $1
+		FOR UPDATE SKIP LOCKED
+	)
+	RETURNING id, kind, COALESCE(post_id, 0), COALESCE(user_id, 0), COALESCE(followed_id, 0), attempts`
+	rows, err := db.QueryContext(ctx, query, limit, lease.Seconds())
+	if err != nil {
+		log.Errorf("failed to claim timeline jobs: %v", err)
+		return nil, fmt.Errorf("failed to claim timeline jobs: %w", mapDbError(err))
+	}
+	defer rows.Close()
+
+	var jobs []timelineJob
+	for rows.Next() {
+		var job timelineJob
+		if err := rows.Scan(&job.id, &job.kind, &job.postId, &job.userId, &job.followedId, &job.attempts); err != nil {
+			return nil, mapDbError(err)
+		}
+		jobs = append(jobs, job)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, mapDbError(err)
+	}
+
+	sort.Slice(jobs, func(i, j int) bool { return jobs[i].id < jobs[j].id })
+	return jobs, nil
+}
+
+func FinishTimelineJob(ctx context.Context, db *sql.DB, id int64) error {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+	query := `DELETE FROM home_timeline_jobs WHERE id = $1`
+	_, err := db.ExecContext(ctx, query, id)
+	if err != nil {
+		log.Errorf("failed to finish timeline job: %v", err)
+		return fmt.Errorf("failed to finish timeline job: %w", mapDbError(err))
+	}
+	return nil
+}
+
+func HomeTimelinesEmpty(ctx context.Context, db *sql.DB) (bool, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+	var empty bool
+	query := `SELECT NOT EXISTS (SELECT 1 FROM home_timeline)`
+	err := db.QueryRowContext(ctx, query).Scan(&empty)
+	if err != nil {
+		log.Errorf("failed to check timelines: %v", err)
+		return false, fmt.Errorf("failed to check timelines: %w", mapDbError(err))
+	}
+	return empty, nil
+}
+
+// ClearHomeTimelines drops the materialized feeds and their
+// queue. They aren't kept up to date with the join strategy,
+// so they are rebuilt when fan out is turned on again.
+func ClearHomeTimelines(ctx context.Context, db *sql.DB) error {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+	query := `DELETE FROM home_timeline_jobs; DELETE FROM home_timeline;`
+	_, err := db.ExecContext(ctx, query)
+	if err != nil {
+		log.Errorf("failed to clear timelines: %v", err)
+		return fmt.Errorf("failed to clear timelines: %w", mapDbError(err))
+	}
+	return nil
+}
+
+// RebuildHomeTimelines fills every Follows feed from follows
+// and posts, like a backfill of everyone a user follows. Users
+// are rebuilt in batches of ids, each in its own transaction.
+// It returns the number of users rebuilt.
+func RebuildHomeTimelines(ctx context.Context, db *sql.DB, size int, batch int) (int, error) {
+	lastId, err := maxId(ctx, db, "users")
+	if err != nil {
+		return 0, err
+	}
+	rebuilt := 0
+	for from := int64(0); from < lastId; from += int64(batch) {
+		if err := ctx.Err(); err != nil {
+			return rebuilt, err
+		}
+		count, err := rebuildHomeTimelineBatch(ctx, db, from, min(from + int64(batch), lastId), size)
+		if err != nil {
+			return rebuilt, err
+		}
+		rebuilt += count
+	}
+	log.Info("Rebuilt home timelines", "users", rebuilt)
+	return rebuilt, nil
+}
+
+func rebuildHomeTimelineBatch(ctx context.Context, db *sql.DB, from int64, to int64, size int) (int, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		log.Errorf("failed to start transaction: %v", err)
+		return 0, fmt.Errorf("failed to start transaction: %w", mapDbError(err))
+	}
+	defer tx.Rollback()
+
+	query := `DELETE FROM home_timeline WHERE user_id > $1 AND user_id <= $2`
+	_, err = tx.ExecContext(ctx, query, from, to)
+	if err != nil {
+		log.Errorf("failed to clear timelines: %v", err)
+		return 0, fmt.Errorf("failed to clear timelines: %w", mapDbError(err))
+	}
+
+	query = `
+	INSERT INTO home_timeline (user_id, post_id, author_id, created_at)
+	SELECT user_id, post_id, author_id, created_at
+	FROM (
+		SELECT f.user_id, p.id AS post_id, p.user_id AS author_id, p.created_at,
+		       ROW_NUMBER() OVER (PARTITION BY f.user_id ORDER BY p.created_at DESC) AS position
+		FROM follows f
+		INNER JOIN posts p ON p.user_id = f.followed_id
+		WHERE f.user_id > $1 AND f.user_id <= $2
+	) ranked
+	WHERE position <= $3`
+	_, err = tx.ExecContext(ctx, query, from, to, size)
+	if err != nil {
+		log.Errorf("failed to rebuild timelines: %v", err)
+		return 0, fmt.Errorf("failed to rebuild timelines: %w", mapDbError(err))
+	}
+
+	var users int
+	query = `SELECT COUNT(*) FROM users WHERE id > $1 AND id <= $2`
+	if err = tx.QueryRowContext(ctx, query, from, to).Scan(&users); err != nil {
+		log.Errorf("failed to count users: %v", err)
+		return 0, fmt.Errorf("failed to count users: %w", mapDbError(err))
+	}
+
+	if err = tx.Commit(); err != nil {
+		log.Errorf("failed to commit rebuild: %v", err)
+		return 0, fmt.Errorf("failed to commit rebuild: %w", mapDbError(err))
+	}
+	return users, nil
+}
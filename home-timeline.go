@@ -0,0 +1,205 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/charmbracelet/log"
+)
+
+type TimelineStrategy string
+
+const (
+	// joinStrategy builds the Follows feed from posts and follows
+	// on every refresh.
+	joinStrategy   TimelineStrategy = "join"
+	// fanOutStrategy reads the Follows feed from home_timeline,
+	// which is filled when posts are written.
+	fanOutStrategy TimelineStrategy = "fanout"
+)
+
+type timelineJobType string
+
+const (
+	fanOutJob   timelineJobType = "fanout"
+	backfillJob timelineJobType = "backfill"
+	removeJob   timelineJobType = "remove"
+	rebuildJob  timelineJobType = "rebuild"
+)
+
+type timelineJob struct {
+	id         int64
+	kind       timelineJobType
+	postId     int64
+	userId     int64
+	followedId int64
+	attempts   int
+}
+
+const (
+	timelineInterval    = 5 * time.Second
+	timelineLease       = 5 * time.Minute
+	timelineBatch       = 100
+	timelineMaxAttempts = 5
+	rebuildBatch        = 1000
+)
+
+// HomeTimeline keeps the materialized Follows feeds up to date.
+// Jobs wait in home_timeline_jobs, so they survive restarts and
+// are written by any instance, including ones queued by the
+// seed and import commands. They are handled in order, so a
+// follow and a quick unfollow are applied in order.
+type HomeTimeline struct {
+	db     *sql.DB
+	size   int
+	wake   chan struct{}
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+var homeTimeline *HomeTimeline
+
+func StartHomeTimeline(db *sql.DB, size int) *HomeTimeline {
+	ctx, cancel := context.WithCancel(context.Background())
+	timeline := &HomeTimeline{
+		db: db,
+		size: size,
+		wake: make(chan struct{}, 1),
+		cancel: cancel,
+		done: make(chan struct{}),
+	}
+	go timeline.run(ctx)
+	homeTimeline = timeline
+	return timeline
+}
+
+func (t *HomeTimeline) run(ctx context.Context) {
+	defer close(t.done)
+	ticker := time.NewTicker(timelineInterval)
+	defer ticker.Stop()
+	for {
+		t.handleQueued(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		case <-t.wake:
+		}
+	}
+}
+
+func (t *HomeTimeline) handleQueued(ctx context.Context) {
+	for ctx.Err() == nil {
+		jobs, err := ClaimTimelineJobs(ctx, t.db, timelineBatch, timelineLease)
+		if err != nil {
+			log.Error("Could not read home timeline queue", "error", err)
+			return
+		}
+		if len(jobs) == 0 {
+			return
+		}
+		for _, job := range jobs {
+			if ctx.Err() != nil {
+				// Claimed jobs are taken again once the lease ends.
+				return
+			}
+			t.handle(ctx, job)
+		}
+	}
+}
+
+func (t *HomeTimeline) handle(ctx context.Context, job timelineJob) {
+	var err error
+	// Single jobs are short, so they finish even when stopping.
+	jobCtx := context.WithoutCancel(ctx)
+	switch job.kind {
+	case fanOutJob:
+		err = FanOutPost(jobCtx, t.db, job.postId, t.size)
+	case backfillJob:
+		err = BackfillHomeTimeline(jobCtx, t.db, job.userId, job.followedId, t.size)
+	case removeJob:
+		err = RemoveFromHomeTimeline(jobCtx, t.db, job.userId, job.followedId)
+	case rebuildJob:
+		_, err = RebuildHomeTimelines(ctx, t.db, t.size, rebuildBatch)
+	default:
+		err = fmt.Errorf("unknown job %q", job.kind)
+	}
+	if err != nil && job.attempts < timelineMaxAttempts {
+		log.Error("Home timeline job failed", "job", job.id, "kind", job.kind, "attempts", job.attempts, "error", err)
+		return
+	}
+	if err != nil {
+		log.Error("Giving up on home timeline job", "job", job.id, "kind", job.kind, "error", err)
+	}
+	if err := FinishTimelineJob(jobCtx, t.db, job.id); err != nil {
+		log.Error("Could not remove home timeline job", "job", job.id, "error", err)
+	}
+}
+
+func (t *HomeTimeline) enqueue(ctx context.Context, job timelineJob) {
+	if err := EnqueueTimelineJob(context.WithoutCancel(ctx), t.db, job); err != nil {
+		log.Error("Could not queue home timeline job", "kind", job.kind, "error", err)
+		return
+	}
+	select {
+	case t.wake <- struct{}{}:
+	default:
+	}
+}
+
+// BackfillIfEmpty queues a rebuild of all feeds when there are
+// none yet, like after switching to fan out.
+func (t *HomeTimeline) BackfillIfEmpty(ctx context.Context) error {
+	empty, err := HomeTimelinesEmpty(ctx, t.db)
+	if err != nil || !empty {
+		return err
+	}
+	log.Info("Home timelines are empty, rebuilding them")
+	t.enqueue(ctx, timelineJob{kind: rebuildJob})
+	return nil
+}
+
+// Stop waits for the job in progress. Queued jobs stay in the
+// table for the next start. It is safe to queue jobs after it.
+func (t *HomeTimeline) Stop() {
+	t.cancel()
+	<-t.done
+}
+
+func PostCreated(ctx context.Context, postId int64) {
+	if homeTimeline == nil {
+		return
+	}
+	homeTimeline.enqueue(ctx, timelineJob{kind: fanOutJob, postId: postId})
+}
+
+func FollowCreated(ctx context.Context, user SavedUser, followed SavedUser) {
+	if homeTimeline == nil {
+		return
+	}
+	homeTimeline.enqueue(ctx, timelineJob{kind: backfillJob, userId: user.id, followedId: followed.id})
+}
+
+func FollowDeleted(ctx context.Context, user SavedUser, followed SavedUser) {
+	if homeTimeline == nil {
+		return
+	}
+	homeTimeline.enqueue(ctx, timelineJob{kind: removeJob, userId: user.id, followedId: followed.id})
+}
+
+// RequestTimelineRebuild queues a rebuild for whichever server
+// keeps the feeds, after data is written outside of it.
+func RequestTimelineRebuild(ctx context.Context, db *sql.DB) error {
+	return EnqueueTimelineJob(ctx, db, timelineJob{kind: rebuildJob})
+}
+
+// FindFollowsFeed reads the Follows feed using the configured
+// strategy.
+func FindFollowsFeed(ctx context.Context, db *sql.DB, viewer SavedUser) ([]Post, error) {
+	if homeTimeline == nil {
+		return FindFollowedPosts(ctx, db, viewer)
+	}
+	return FindHomeTimelinePosts(ctx, db, viewer)
+}
@@ -0,0 +1,304 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/log"
+)
+
+const (
+	defaultPageSize = 20
+	maxPageSize     = 100
+)
+
+type apiProfile struct {
+	Username    string    `json:"username"`
+	Description string    `json:"description,omitempty"`
+	Location    string    `json:"location,omitempty"`
+	Followers   int       `json:"followers"`
+	Following   int       `json:"following"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+type apiPollOption struct {
+	Content string `json:"content"`
+	Votes   int    `json:"votes"`
+}
+
+type apiPoll struct {
+	EndsAt  time.Time       `json:"ends_at"`
+	Closed  bool            `json:"closed"`
+	Votes   int             `json:"votes"`
+	Options []apiPollOption `json:"options"`
+}
+
+type apiPost struct {
+	Id             int64     `json:"id"`
+	Author         string    `json:"author"`
+	Content        string    `json:"content"`
+	ContentWarning string    `json:"content_warning,omitempty"`
+	CreatedAt      time.Time `json:"created_at"`
+	Likes          int       `json:"likes"`
+	Replies        int       `json:"replies"`
+	ReplyTo        int64     `json:"reply_to,omitempty"`
+	Poll           *apiPoll  `json:"poll,omitempty"`
+}
+
+type apiPage struct {
+	Items []apiPost `json:"items"`
+	Next  string    `json:"next,omitempty"`
+}
+
+type apiThread struct {
+	Post    apiPost `json:"post"`
+	Replies apiPage `json:"replies"`
+}
+
+type apiError struct {
+	Error string `json:"error"`
+}
+
+func toApiProfile(user SavedUser) apiProfile {
+	return apiProfile{
+		Username: user.username,
+		Description: user.description.String,
+		Location: user.location.String,
+		Followers: user.followers,
+		Following: user.followed,
+		CreatedAt: user.createdAt,
+	}
+}
+
+func toApiPost(post Post) apiPost {
+	result := apiPost{
+		Id: post.id,
+		Author: post.username,
+		Content: post.content,
+		ContentWarning: post.contentWarning.String,
+		CreatedAt: post.createdAt,
+		Likes: post.likes,
+		Replies: post.replies,
+		ReplyTo: post.parentId.Int64,
+	}
+	if post.poll != nil {
+		poll := &apiPoll{
+			EndsAt: post.poll.endsAt,
+			Closed: post.poll.Closed(),
+			Votes: post.poll.votes,
+			Options: make([]apiPollOption, 0, len(post.poll.options)),
+		}
+		for _, option := range post.poll.options {
+			poll.Options = append(poll.Options, apiPollOption{Content: option.content, Votes: option.votes})
+		}
+		result.Poll = poll
+	}
+	return result
+}
+
+// FindPostsPageFunc reads limit posts, skipping offset of them.
+type FindPostsPageFunc func(ctx context.Context, db *sql.DB, viewer SavedUser, limit int, offset int) ([]Post, error)
+
+// ApiHandler serves public data as JSON. It never writes to
+// the database, and reads as an anonymous viewer.
+type ApiHandler struct {
+	db *sql.DB
+}
+
+func NewApiHandler(db *sql.DB) ApiHandler {
+	return ApiHandler{db: db}
+}
+
+func (h ApiHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		w.Header().Set("Allow", "GET, HEAD")
+		writeJSON(w, r, http.StatusMethodNotAllowed, apiError{"read-only API"})
+		return
+	}
+	path := strings.Trim(strings.TrimPrefix(r.URL.Path, "/api"), "/")
+	parts := strings.Split(path, "/")
+	switch {
+	case path == "feed":
+		h.servePosts(w, r, FindAllPostsPage)
+	case len(parts) == 2 && parts[0] == "users":
+		h.serveProfile(w, r, parts[1])
+	case len(parts) == 3 && parts[0] == "users" && parts[2] == "posts":
+		h.serveUserPosts(w, r, parts[1])
+	case len(parts) == 2 && parts[0] == "posts":
+		h.serveThread(w, r, parts[1])
+	default:
+		writeJSON(w, r, http.StatusNotFound, apiError{"not found"})
+	}
+}
+
+func (h ApiHandler) serveProfile(w http.ResponseWriter, r *http.Request, username string) {
+	user, ok := h.findUser(w, r, username)
+	if !ok {
+		return
+	}
+	writeJSON(w, r, http.StatusOK, toApiProfile(user))
+}
+
+func (h ApiHandler) serveUserPosts(w http.ResponseWriter, r *http.Request, username string) {
+	user, ok := h.findUser(w, r, username)
+	if !ok {
+		return
+	}
+	h.servePosts(w, r, func(ctx context.Context, db *sql.DB, viewer SavedUser, limit int, offset int) ([]Post, error) {
+		return FindUserPostsPage(ctx, db, user, viewer, limit, offset)
+	})
+}
+
+func (h ApiHandler) servePosts(w http.ResponseWriter, r *http.Request, find FindPostsPageFunc) {
+	page, ok := h.findPage(w, r, find)
+	if !ok {
+		return
+	}
+	writeJSON(w, r, http.StatusOK, page)
+}
+
+func (h ApiHandler) serveThread(w http.ResponseWriter, r *http.Request, param string) {
+	id, err := strconv.ParseInt(param, 10, 64)
+	if err != nil {
+		writeJSON(w, r, http.StatusNotFound, apiError{"not found"})
+		return
+	}
+	post, err := GetPostById(r.Context(), h.db, id, SavedUser{})
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	posts := []Post{post}
+	if err := AttachPolls(r.Context(), h.db, posts, SavedUser{}); err != nil {
+		log.Error(err)
+	}
+	replies, ok := h.findPage(w, r, func(ctx context.Context, db *sql.DB, viewer SavedUser, limit int, offset int) ([]Post, error) {
+		return FindRepliesPage(ctx, db, id, viewer, limit, offset)
+	})
+	if !ok {
+		return
+	}
+	writeJSON(w, r, http.StatusOK, apiThread{Post: toApiPost(posts[0]), Replies: replies})
+}
+
+func (h ApiHandler) findUser(w http.ResponseWriter, r *http.Request, username string) (SavedUser, bool) {
+	user, err := GetUserByUsername(r.Context(), h.db, username)
+	if err == nil && !user.verified {
+		err = ErrNotFound
+	}
+	if err != nil {
+		writeError(w, r, err)
+		return user, false
+	}
+	return user, true
+}
+
+// findPage loads the page selected by the limit and offset
+// parameters. One more post is read to tell whether there is
+// a next page.
+func (h ApiHandler) findPage(w http.ResponseWriter, r *http.Request, find FindPostsPageFunc) (apiPage, bool) {
+	limit, offset, err := pageParams(r.URL.Query())
+	if err != nil {
+		writeJSON(w, r, http.StatusBadRequest, apiError{err.Error()})
+		return apiPage{}, false
+	}
+	posts, err := find(r.Context(), h.db, SavedUser{}, limit + 1, offset)
+	if err != nil {
+		writeError(w, r, err)
+		return apiPage{}, false
+	}
+	page := apiPage{Items: []apiPost{}}
+	if len(posts) > limit {
+		next := *r.URL
+		query := next.Query()
+		query.Set("limit", strconv.Itoa(limit))
+		query.Set("offset", strconv.Itoa(offset + limit))
+		next.RawQuery = query.Encode()
+		page.Next = next.RequestURI()
+	}
+	posts = posts[:min(limit, len(posts))]
+	if err := AttachPolls(r.Context(), h.db, posts, SavedUser{}); err != nil {
+		log.Error(err)
+	}
+	for _, post := range posts {
+		page.Items = append(page.Items, toApiPost(post))
+	}
+	return page, true
+}
+
+func pageParams(query url.Values) (int, int, error) {
+	limit, offset := defaultPageSize, 0
+	var err error
+	if value := query.Get("limit"); value != "" {
+		limit, err = strconv.Atoi(value)
+		if err != nil || limit <= 0 || limit > maxPageSize {
+			return 0, 0, fmt.Errorf("limit must be between 1 and %d", maxPageSize)
+		}
+	}
+	if value := query.Get("offset"); value != "" {
+		offset, err = strconv.Atoi(value)
+		if err != nil || offset < 0 {
+			return 0, 0, fmt.Errorf("offset must not be negative")
+		}
+	}
+	return limit, offset, nil
+}
+
+func writeError(w http.ResponseWriter, r *http.Request, err error) {
+	if errors.Is(err, ErrNotFound) {
+		writeJSON(w, r, http.StatusNotFound, apiError{"not found"})
+		return
+	}
+	log.Error("API request failed", "path", r.URL.Path, "error", err)
+	writeJSON(w, r, http.StatusInternalServerError, apiError{"internal error"})
+}
+
+func writeJSON(w http.ResponseWriter, r *http.Request, status int, v any) {
+	body, err := json.Marshal(v)
+	if err != nil {
+		log.Error("Could not encode response", "error", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	writeBody(w, r, status, "application/json", body)
+}
+
+// writeBody tags successful responses with an ETag of their
+// body and answers 304 when the client already has it.
+func writeBody(w http.ResponseWriter, r *http.Request, status int, contentType string, body []byte) {
+	w.Header().Set("Content-Type", contentType)
+	if status == http.StatusOK {
+		sum := sha256.Sum256(body)
+		etag := `"` + hex.EncodeToString(sum[:16]) + `"`
+		w.Header().Set("ETag", etag)
+		w.Header().Set("Cache-Control", "no-cache")
+		if etagMatches(r.Header.Get("If-None-Match"), etag) {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+	}
+	w.WriteHeader(status)
+	if r.Method != http.MethodHead {
+		w.Write(body)
+	}
+}
+
+func etagMatches(header string, etag string) bool {
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimPrefix(strings.TrimSpace(candidate), "W/")
+		if candidate == etag || candidate == "*" {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"bytes"
+	"database/sql"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/charmbracelet/log"
+)
+
+// FeedHandler serves Atom and RSS documents at
+// /feeds/global.atom, /feeds/users/{username}.rss and
+// /feeds/tags/{tag}.atom.
+type FeedHandler struct {
+	db *sql.DB
+}
+
+func NewFeedHandler(db *sql.DB) FeedHandler {
+	return FeedHandler{db: db}
+}
+
+func (h FeedHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		w.Header().Set("Allow", "GET, HEAD")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	path := strings.Trim(strings.TrimPrefix(r.URL.Path, "/feeds"), "/")
+	dot := strings.LastIndex(path, ".")
+	if dot < 0 {
+		http.NotFound(w, r)
+		return
+	}
+	format := FeedFormat(path[dot+1:])
+	if format != atomFormat && format != rssFormat {
+		http.NotFound(w, r)
+		return
+	}
+	kind, name, _ := strings.Cut(path[:dot], "/")
+	switch kind {
+	case "users":
+		kind = "user"
+	case "tags":
+		kind = "tag"
+	case "global":
+		if name != "" {
+			http.NotFound(w, r)
+			return
+		}
+	default:
+		http.NotFound(w, r)
+		return
+	}
+
+	syndication, err := FindSyndication(r.Context(), h.db, kind, name)
+	if err != nil {
+		if errors.Is(err, ErrNotFound) {
+			http.NotFound(w, r)
+			return
+		}
+		log.Error("Feed request failed", "path", r.URL.Path, "error", err)
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+	var body bytes.Buffer
+	if err := WriteSyndication(&body, syndication, format); err != nil {
+		log.Error("Could not encode feed", "error", err)
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+	writeBody(w, r, http.StatusOK, format.ContentType(), body.Bytes())
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/charmbracelet/log"
+)
+
+func newHttpMux(db *sql.DB, sshAddr string) *http.ServeMux {
+	mux := http.NewServeMux()
+	health := NewHealthHandler(db, sshAddr)
+	mux.Handle("/healthz", health)
+	mux.Handle("/readyz", health)
+	mux.Handle("/api/", NewApiHandler(db))
+	mux.Handle("/feeds/", NewFeedHandler(db))
+	return mux
+}
+
+// StartHttpServer serves read-only endpoints next to the SSH
+// server. Stop it with Shutdown.
+func StartHttpServer(addr string, db *sql.DB, sshAddr string) *http.Server {
+	return startServer("HTTP", addr, newHttpMux(db, sshAddr))
+}
+
+// StartMetricsServer serves /metrics on its own address, which
+// is usually kept private, unlike the public API.
+func StartMetricsServer(addr string) *http.Server {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/metrics", metricsHandler)
+	return startServer("metrics", addr, mux)
+}
+
+func startServer(name string, addr string, handler http.Handler) *http.Server {
+	server := &http.Server{
+		Addr: addr,
+		Handler: handler,
+		ReadHeaderTimeout: 5 * time.Second,
+	}
+	go func() {
+		log.Info("Starting "+name+" server", "address", addr)
+		if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Error("Could not start "+name+" server", "error", err)
+		}
+	}()
+	return server
+}
@@ -1,12 +1,12 @@
 package main
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
 	"time"
 
 	"github.com/charmbracelet/log"
-	"github.com/lib/pq"
 )
 
 type Like struct {
@@ -55,24 +55,29 @@ func CreateLikeFunction(db *sql.DB) {
 	log.Info("Procedure created successfully!")
 }
 
-func SaveLike(db *sql.DB, user SavedUser, post Post) error {
+func SaveLike(ctx context.Context, db *sql.DB, user SavedUser, post Post) error {
+	if err := checkWritable(); err != nil {
+		return err
+	}
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
 	log.Info("Saving like to db")
 	query := `CALL add_like($1, $2)`
 
-	_, err := db.Exec(query, user.id, post.id)
+	_, err := db.ExecContext(ctx, query, user.id, post.id)
 
 	if err != nil {
-		if pqErr, ok := err.(*pq.Error); ok {
-			if pqErr.Code == "23505" {
-				log.Warnf("Already liked: %v", err)
-				return fmt.Errorf("Already liked: %v", err)
-			}
+		err = mapDbError(err)
+		if IsDomainError(err) {
+			log.Warn(err)
+			return err
 		}
 		log.Errorf("failed to insert like: %v", err)
-		return fmt.Errorf("failed to insert like: %v", err)
+		return fmt.Errorf("failed to insert like: %w", mapDbError(err))
 	}
 
 	log.Info("Saved new like")
+	likesCreated.Inc()
 	return nil
 }
 
@@ -107,21 +112,25 @@ func CreateUnlikeFunction(db *sql.DB) {
 	log.Info("Procedure created successfully!")
 }
 
-func DeleteLike(db *sql.DB, user SavedUser, post Post) error {
+func DeleteLike(ctx context.Context, db *sql.DB, user SavedUser, post Post) error {
+	if err := checkWritable(); err != nil {
+		return err
+	}
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
 	log.Info("Deleting like in db")
 	query := `CALL delete_like($1, $2)`
 
-	_, err := db.Exec(query, user.id, post.id)
+	_, err := db.ExecContext(ctx, query, user.id, post.id)
 
 	if err != nil {
-		if pqErr, ok := err.(*pq.Error); ok {
-			if pqErr.Code == "P0001" {
-				log.Warnf("Not liking: %v", err)
-				return fmt.Errorf("Not liking: %v", err)
-			}
+		err = mapDbError(err)
+		if IsDomainError(err) {
+			log.Warn(err)
+			return err
 		}
 		log.Errorf("failed to delete like: %v", err)
-		return  fmt.Errorf("failed to delete like: %v", err)
+		return  fmt.Errorf("failed to delete like: %w", mapDbError(err))
 	}
 
 	log.Info("Deleted like")
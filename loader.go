@@ -0,0 +1,88 @@
+package main
+
+import (
+	"sync/atomic"
+
+	"github.com/charmbracelet/bubbles/spinner"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+var lastTabId atomic.Int64
+
+// nextTabId gives every tab model an id, so results of its
+// commands can find their way back to it.
+func nextTabId() int64 {
+	return lastTabId.Add(1)
+}
+
+func CreateLoader(renderer *lipgloss.Renderer) Loader {
+	spinnerStyle := renderer.NewStyle().Foreground(lipgloss.Color("#1da1f2"))
+	labelStyle := renderer.NewStyle().Foreground(lipgloss.Color("8"))
+
+	return Loader{
+		spinner: spinner.New(
+			spinner.WithSpinner(spinner.Dot),
+			spinner.WithStyle(spinnerStyle),
+		),
+		labelStyle: labelStyle,
+	}
+}
+
+// Loader is a one line spinner shown while a tab waits for
+// the database.
+type Loader struct {
+	spinner      spinner.Model
+	pending      int
+	label        string
+	labelStyle   lipgloss.Style
+}
+
+func (l *Loader) Start(label string) tea.Cmd {
+	l.pending += 1
+	l.label = label
+	if l.pending == 1 {
+		return l.spinner.Tick
+	}
+	return nil
+}
+
+// Tick keeps the spinner going for work started before
+// the model was handed over to the program.
+func (l Loader) Tick() tea.Cmd {
+	if l.pending == 0 {
+		return nil
+	}
+	return l.spinner.Tick
+}
+
+func (l *Loader) Done() {
+	l.pending = max(l.pending - 1, 0)
+}
+
+func (l Loader) Loading() bool {
+	return l.pending > 0
+}
+
+func (l Loader) Update(msg tea.Msg) (Loader, tea.Cmd) {
+	if _, ok := msg.(spinner.TickMsg); !ok || l.pending == 0 {
+		return l, nil
+	}
+	var cmd tea.Cmd
+	l.spinner, cmd = l.spinner.Update(msg)
+	return l, cmd
+}
+
+func (l Loader) Height() int {
+	if l.pending == 0 {
+		return 0
+	}
+	return 1
+}
+
+func (l Loader) View() string {
+	if l.pending == 0 {
+		return ""
+	}
+	return l.spinner.View() + " " + l.labelStyle.Render(l.label)
+}
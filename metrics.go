@@ -0,0 +1,309 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"io"
+	"net/http"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/charmbracelet/ssh"
+	"github.com/charmbracelet/wish"
+)
+
+// The metrics below are written in the Prometheus text format.
+// Every metric is registered once at startup; labeled metrics
+// create their series on first use.
+
+type metric interface {
+	write(w io.Writer)
+}
+
+var metrics []metric
+
+type Counter struct {
+	name   string
+	help   string
+	mu     sync.Mutex
+	values map[string]*atomic.Int64
+}
+
+func newCounter(name string, help string) *Counter {
+	c := &Counter{name: name, help: help, values: map[string]*atomic.Int64{}}
+	metrics = append(metrics, c)
+	return c
+}
+
+func (c *Counter) series(labels string) *atomic.Int64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	value, ok := c.values[labels]
+	if !ok {
+		value = &atomic.Int64{}
+		c.values[labels] = value
+	}
+	return value
+}
+
+func (c *Counter) Inc() {
+	c.series("").Add(1)
+}
+
+// With returns the series for label pairs, like
+// With("result", "success").
+func (c *Counter) With(labels ...string) *atomic.Int64 {
+	return c.series(formatLabels(labels))
+}
+
+func (c *Counter) write(w io.Writer) {
+	writeHeader(w, c.name, c.help, "counter")
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, labels := range sortedKeys(c.values) {
+		fmt.Fprintf(w, "%s%s %d\n", c.name, labels, c.values[labels].Load())
+	}
+}
+
+type Gauge struct {
+	name   string
+	help   string
+	mu     sync.Mutex
+	values map[string]*atomic.Int64
+}
+
+func newGauge(name string, help string) *Gauge {
+	g := &Gauge{name: name, help: help, values: map[string]*atomic.Int64{}}
+	metrics = append(metrics, g)
+	return g
+}
+
+func (g *Gauge) With(labels ...string) *atomic.Int64 {
+	key := formatLabels(labels)
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	value, ok := g.values[key]
+	if !ok {
+		value = &atomic.Int64{}
+		g.values[key] = value
+	}
+	return value
+}
+
+func (g *Gauge) write(w io.Writer) {
+	writeHeader(w, g.name, g.help, "gauge")
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	for _, labels := range sortedKeys(g.values) {
+		fmt.Fprintf(w, "%s%s %d\n", g.name, labels, g.values[labels].Load())
+	}
+}
+
+type histogramSeries struct {
+	counts []uint64
+	count  uint64
+	sum    float64
+}
+
+type Histogram struct {
+	name    string
+	help    string
+	label   string
+	buckets []float64
+	mu      sync.Mutex
+	series  map[string]*histogramSeries
+}
+
+func newHistogram(name string, help string, label string, buckets []float64) *Histogram {
+	h := &Histogram{name: name, help: help, label: label, buckets: buckets, series: map[string]*histogramSeries{}}
+	metrics = append(metrics, h)
+	return h
+}
+
+func (h *Histogram) Observe(value string, seconds float64) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	s, ok := h.series[value]
+	if !ok {
+		s = &histogramSeries{counts: make([]uint64, len(h.buckets))}
+		h.series[value] = s
+	}
+	for i, bound := range h.buckets {
+		if seconds <= bound {
+			s.counts[i] += 1
+		}
+	}
+	s.count += 1
+	s.sum += seconds
+}
+
+func (h *Histogram) write(w io.Writer) {
+	writeHeader(w, h.name, h.help, "histogram")
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for _, value := range sortedKeys(h.series) {
+		s := h.series[value]
+		label := fmt.Sprintf("%s=%q", h.label, value)
+		for i, bound := range h.buckets {
+			le := strconv.FormatFloat(bound, 'g', -1, 64)
+			fmt.Fprintf(w, "%s_bucket{%s,le=%q} %d\n", h.name, label, le, s.counts[i])
+		}
+		fmt.Fprintf(w, "%s_bucket{%s,le=\"+Inf\"} %d\n", h.name, label, s.count)
+		fmt.Fprintf(w, "%s_sum{%s} %s\n", h.name, label, strconv.FormatFloat(s.sum, 'g', -1, 64))
+		fmt.Fprintf(w, "%s_count{%s} %d\n", h.name, label, s.count)
+	}
+}
+
+// gaugeFunc reads its value when metrics are scraped.
+type gaugeFunc struct {
+	name  string
+	help  string
+	kind  string
+	value func() float64
+}
+
+func newGaugeFunc(name string, help string, kind string, value func() float64) {
+	metrics = append(metrics, gaugeFunc{name, help, kind, value})
+}
+
+func (g gaugeFunc) write(w io.Writer) {
+	writeHeader(w, g.name, g.help, g.kind)
+	fmt.Fprintf(w, "%s %s\n", g.name, strconv.FormatFloat(g.value(), 'g', -1, 64))
+}
+
+func writeHeader(w io.Writer, name string, help string, kind string) {
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, kind)
+}
+
+func formatLabels(labels []string) string {
+	if len(labels) == 0 {
+		return ""
+	}
+	pairs := make([]string, 0, len(labels)/2)
+	for i := 0; i+1 < len(labels); i += 2 {
+		pairs = append(pairs, fmt.Sprintf("%s=%q", labels[i], labels[i+1]))
+	}
+	return "{" + strings.Join(pairs, ",") + "}"
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+var (
+	activeSessions = newGauge("sshwitter_ssh_sessions_active",
+		"SSH sessions currently open.")
+	modelSessions = newGauge("sshwitter_ssh_sessions_by_model",
+		"Interactive sessions currently open by model type.")
+	authAttempts = newCounter("sshwitter_auth_attempts_total",
+		"Authentication attempts by result.")
+	postsCreated = newCounter("sshwitter_posts_created_total",
+		"Posts and replies created.")
+	likesCreated = newCounter("sshwitter_likes_created_total",
+		"Likes created.")
+	followsCreated = newCounter("sshwitter_follows_created_total",
+		"Follows created.")
+	rateLimited = newCounter("sshwitter_rate_limited_total",
+		"Actions refused by rate limits.")
+	queryDuration = newHistogram("sshwitter_db_query_duration_seconds",
+		"Latency of repository functions.", "function",
+		[]float64{0.001, 0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5})
+)
+
+// RegisterDbMetrics exports the connection pool statistics of db.
+func RegisterDbMetrics(db *sql.DB) {
+	stat := func(read func(sql.DBStats) float64) func() float64 {
+		return func() float64 { return read(db.Stats()) }
+	}
+	newGaugeFunc("sshwitter_db_max_open_connections", "Maximum number of open connections.", "gauge",
+		stat(func(s sql.DBStats) float64 { return float64(s.MaxOpenConnections) }))
+	newGaugeFunc("sshwitter_db_open_connections", "Open connections.", "gauge",
+		stat(func(s sql.DBStats) float64 { return float64(s.OpenConnections) }))
+	newGaugeFunc("sshwitter_db_in_use_connections", "Connections in use.", "gauge",
+		stat(func(s sql.DBStats) float64 { return float64(s.InUse) }))
+	newGaugeFunc("sshwitter_db_idle_connections", "Idle connections.", "gauge",
+		stat(func(s sql.DBStats) float64 { return float64(s.Idle) }))
+	newGaugeFunc("sshwitter_db_wait_count_total", "Connections waited for.", "counter",
+		stat(func(s sql.DBStats) float64 { return float64(s.WaitCount) }))
+	newGaugeFunc("sshwitter_db_wait_duration_seconds_total", "Time spent waiting for connections.", "counter",
+		stat(func(s sql.DBStats) float64 { return s.WaitDuration.Seconds() }))
+	newGaugeFunc("sshwitter_db_max_idle_closed_total", "Connections closed because of SetMaxIdleConns.", "counter",
+		stat(func(s sql.DBStats) float64 { return float64(s.MaxIdleClosed) }))
+	newGaugeFunc("sshwitter_db_max_lifetime_closed_total", "Connections closed because of SetConnMaxLifetime.", "counter",
+		stat(func(s sql.DBStats) float64 { return float64(s.MaxLifetimeClosed) }))
+}
+
+// sessionMetricsMiddleware counts open SSH sessions, including
+// sessions running a command.
+func sessionMetricsMiddleware() wish.Middleware {
+	return func(next ssh.Handler) ssh.Handler {
+		return func(s ssh.Session) {
+			sessions := activeSessions.With()
+			sessions.Add(1)
+			defer sessions.Add(-1)
+			next(s)
+		}
+	}
+}
+
+func WriteMetrics(w io.Writer) {
+	for _, m := range metrics {
+		m.write(w)
+	}
+}
+
+func metricsHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	WriteMetrics(w)
+}
+
+// timedHelpers run queries for another repository function,
+// which is the one their latency is reported for.
+var timedHelpers = map[string]bool{
+	"execTx":                   true,
+	"queryRowTx":               true,
+	"updateUser":               true,
+	"findPostsPage":            true,
+	"findQuery":                true,
+	"findAnnouncements":        true,
+	"exportPosts":              true,
+	"exportLikes":              true,
+	"exportUsernames":          true,
+	"maxId":                    true,
+	"reconcileBatches":         true,
+	"rebuildHomeTimelineBatch": true,
+}
+
+// repositoryFunction names the function that called withTimeout.
+func repositoryFunction() string {
+	pc := make([]uintptr, 4)
+	n := runtime.Callers(4, pc)
+	frames := runtime.CallersFrames(pc[:n])
+	for {
+		frame, more := frames.Next()
+		name := frame.Function[strings.LastIndex(frame.Function, ".")+1:]
+		if !timedHelpers[name] || !more {
+			return name
+		}
+	}
+}
+
+// observeQuery returns a function that records the time since
+// it was created for the calling repository function.
+func observeQuery() func() {
+	name := repositoryFunction()
+	start := time.Now()
+	return func() {
+		queryDuration.Observe(name, time.Since(start).Seconds())
+	}
+}
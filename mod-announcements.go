@@ -0,0 +1,192 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"strconv"
+	"strings"
+
+	"github.com/charmbracelet/bubbles/table"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+func createAnnouncementTable() table.Model {
+	columns := []table.Column{
+		{Title: "Id", Width: 4},
+		{Title: "Severity", Width: 9},
+		{Title: "Expires", Width: 16},
+		{Title: "Text", Width: 50},
+	}
+	return table.New(
+		table.WithColumns(columns),
+		table.WithFocused(true),
+		table.WithHeight(10),
+	)
+}
+
+func createAnnouncementInput(renderer *lipgloss.Renderer) CustomInput {
+	input := CreateCustomInput(renderer, "Announcement", "Maintenance tonight at 22:00", announcementValidator, false)
+	input.Input.CharLimit = 200
+	input.Input.Width = 60
+	return input
+}
+
+type ModAnnouncementsMsg struct {
+	tab           int64
+	announcements []Announcement
+	err           error
+}
+
+func loadModAnnouncements(ctx context.Context, tab int64, db *sql.DB) tea.Cmd {
+	return func() tea.Msg {
+		if ctx.Err() != nil {
+			return nil
+		}
+		announcements, err := FindAnnouncements(ctx, db)
+		return ModAnnouncementsMsg{tab: tab, announcements: announcements, err: err}
+	}
+}
+
+// publishAnnouncement saves the announcement and shows it in
+// every connected session.
+func publishAnnouncement(ctx context.Context, tab int64, db *sql.DB, author SavedUser, content string, severity Severity, expiry string) tea.Cmd {
+	return func() tea.Msg {
+		if ctx.Err() != nil {
+			return nil
+		}
+		expiresAt, err := ParseExpiry(expiry)
+		if err != nil {
+			return ModeratorErrorMsg{tab, err.Error(), err}
+		}
+		announcement, err := SaveAnnouncement(ctx, db, author, content, severity, expiresAt)
+		if err != nil {
+			return ModeratorErrorMsg{tab, "Could not publish the announcement", err}
+		}
+		programs.Broadcast(AnnouncementMsg{announcement: announcement})
+		return loadModAnnouncements(ctx, tab, db)()
+	}
+}
+
+func withdrawAnnouncement(ctx context.Context, tab int64, db *sql.DB, id int64) tea.Cmd {
+	return func() tea.Msg {
+		if ctx.Err() != nil {
+			return nil
+		}
+		if err := DeleteAnnouncement(ctx, db, id); err != nil {
+			return ModeratorErrorMsg{tab, "Could not delete the announcement", err}
+		}
+		programs.Broadcast(AnnouncementRemovedMsg{id: id})
+		return loadModAnnouncements(ctx, tab, db)()
+	}
+}
+
+func (m *ModeratorTabModel) SetAnnouncements(announcements []Announcement) {
+	m.announcements = announcements
+	rows := make([]table.Row, 0, len(announcements))
+	for _, a := range announcements {
+		expires := "never"
+		if a.expiresAt.Valid {
+			expires = a.expiresAt.Time.Format("2006-01-02 15:04")
+		}
+		rows = append(rows, table.Row{strconv.FormatInt(a.id, 10), string(a.severity), expires, SanitizeLine(a.content)})
+	}
+	m.announcementTable.SetRows(rows)
+}
+
+// updateAnnouncementForm handles keys while an announcement is
+// typed; tab moves between the text and the expiry.
+func (m ModeratorTabModel) updateAnnouncementForm(msg tea.KeyMsg) (ModeratorTabModel, tea.Cmd) {
+	switch msg.String() {
+	case "esc":
+		m.announcing = false
+		m.announceInput.Blur()
+		m.expiryInput.Blur()
+		return m, nil
+	case "tab":
+		if m.announceInput.Input.Focused() {
+			m.announceInput.Blur()
+			return m, m.expiryInput.Focus()
+		}
+		m.expiryInput.Blur()
+		return m, m.announceInput.Focus()
+	case "up":
+		m.severity = (m.severity + len(severities) - 1) % len(severities)
+		return m, nil
+	case "down":
+		m.severity = (m.severity + 1) % len(severities)
+		return m, nil
+	case "enter":
+		content := strings.TrimSpace(m.announceInput.Input.Value())
+		expiry := m.expiryInput.Input.Value()
+		if announcementValidator(content) != nil || expiryValidator(expiry) != nil {
+			return m, nil
+		}
+		m.announcing = false
+		m.announceInput.Blur()
+		m.expiryInput.Blur()
+		m.announceInput.Input.SetValue("")
+		m.expiryInput.Input.SetValue("")
+		cmd := tea.Batch(
+			m.loader.Start("Publishing..."),
+			publishAnnouncement(m.ctx, m.id, m.db, m.user, content, severities[m.severity], expiry),
+		)
+		return m, cmd
+	}
+	var cmd tea.Cmd
+	if m.expiryInput.Input.Focused() {
+		m.expiryInput, cmd = m.expiryInput.Update(msg)
+	} else {
+		m.announceInput, cmd = m.announceInput.Update(msg)
+	}
+	return m, cmd
+}
+
+func (m ModeratorTabModel) updateAnnouncements(msg tea.KeyMsg) (ModeratorTabModel, tea.Cmd, bool) {
+	switch msg.String() {
+	case "a":
+		m.announcing = true
+		cmd := m.announceInput.Focus()
+		return m, cmd, true
+	case "r":
+		cmd := tea.Batch(m.loader.Start("Loading announcements..."), loadModAnnouncements(m.ctx, m.id, m.db))
+		return m, cmd, true
+	case "delete":
+		id, ok := selectedId(m.announcementTable)
+		if !ok {
+			return m, nil, true
+		}
+		cmd := tea.Batch(m.loader.Start("Deleting..."), withdrawAnnouncement(m.ctx, m.id, m.db, id))
+		return m, cmd, true
+	}
+	return m, nil, false
+}
+
+func (m ModeratorTabModel) viewAnnouncements() string {
+	doc := strings.Builder{}
+	if m.announcing {
+		doc.WriteString(m.announceInput.View(m.announceInput.Input.Focused()))
+		doc.WriteString("\n")
+		doc.WriteString(m.expiryInput.View(m.expiryInput.Input.Focused()))
+		doc.WriteString("\n")
+		for i, severity := range severities {
+			if i == m.severity {
+				doc.WriteString(m.prefixStyle.Render("⍟ "))
+			} else {
+				doc.WriteString("  ")
+			}
+			doc.WriteString(string(severity))
+			doc.WriteString("\n")
+		}
+		doc.WriteString(m.quitStyle.Render("tab: next field • up/down: severity • enter: publish • esc: cancel"))
+		return doc.String()
+	}
+	if len(m.announcements) > 0 {
+		doc.WriteString(m.announcementTable.View())
+	} else {
+		doc.WriteString(m.quitStyle.Render("No announcements"))
+	}
+	doc.WriteString("\n")
+	doc.WriteString(m.quitStyle.Render("a: add • delete: remove • r: refresh • tab: next view"))
+	return doc.String()
+}
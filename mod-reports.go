@@ -0,0 +1,122 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"strconv"
+	"strings"
+
+	"github.com/charmbracelet/bubbles/table"
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+func createReportTable() table.Model {
+	columns := []table.Column{
+		{Title: "Post", Width: 6},
+		{Title: "Author", Width: 12},
+		{Title: "Reporter", Width: 12},
+		{Title: "Content", Width: 40},
+	}
+	return table.New(
+		table.WithColumns(columns),
+		table.WithFocused(true),
+		table.WithHeight(10),
+	)
+}
+
+type ReportsMsg struct {
+	tab      int64
+	reports  []Report
+	err      error
+}
+
+func loadReports(ctx context.Context, tab int64, db *sql.DB) tea.Cmd {
+	return func() tea.Msg {
+		if ctx.Err() != nil {
+			return nil
+		}
+		reports, err := FindReports(ctx, db)
+		return ReportsMsg{tab: tab, reports: reports, err: err}
+	}
+}
+
+func removeReportedPost(ctx context.Context, tab int64, db *sql.DB, postId int64) tea.Cmd {
+	return func() tea.Msg {
+		if ctx.Err() != nil {
+			return nil
+		}
+		if err := RemovePost(ctx, db, postId); err != nil {
+			return ModeratorErrorMsg{tab, "Could not remove the post", err}
+		}
+		return loadReports(ctx, tab, db)()
+	}
+}
+
+func dismissReports(ctx context.Context, tab int64, db *sql.DB, postId int64) tea.Cmd {
+	return func() tea.Msg {
+		if ctx.Err() != nil {
+			return nil
+		}
+		if err := DismissReports(ctx, db, postId); err != nil {
+			return ModeratorErrorMsg{tab, "Could not dismiss the reports", err}
+		}
+		return loadReports(ctx, tab, db)()
+	}
+}
+
+func (m *ModeratorTabModel) SetReports(reports []Report) {
+	m.reports = reports
+	rows := make([]table.Row, 0, len(reports))
+	for _, r := range reports {
+		rows = append(rows, table.Row{
+			strconv.FormatInt(r.postId, 10),
+			SanitizeLine(r.author),
+			SanitizeLine(r.reporter),
+			SanitizeLine(r.content),
+		})
+	}
+	m.reportTable.SetRows(rows)
+}
+
+// updateReports handles keys of the reports view. Reports are
+// listed per reporter, and are acted on per post.
+func (m ModeratorTabModel) updateReports(msg tea.KeyMsg) (ModeratorTabModel, tea.Cmd, bool) {
+	switch msg.String() {
+	case "r":
+		cmd := tea.Batch(m.loader.Start("Loading reports..."), loadReports(m.ctx, m.id, m.db))
+		return m, cmd, true
+	case "enter":
+		postId, ok := selectedId(m.reportTable)
+		if !ok {
+			return m, nil, true
+		}
+		return m, openPost(postId), true
+	case "x":
+		postId, ok := selectedId(m.reportTable)
+		if !ok {
+			return m, nil, true
+		}
+		cmd := tea.Batch(m.loader.Start("Dismissing..."), dismissReports(m.ctx, m.id, m.db, postId))
+		return m, cmd, true
+	case "delete":
+		postId, ok := selectedId(m.reportTable)
+		if !ok {
+			return m, nil, true
+		}
+		cmd := tea.Batch(m.loader.Start("Removing post..."), removeReportedPost(m.ctx, m.id, m.db, postId))
+		return m, cmd, true
+	}
+	return m, nil, false
+}
+
+func (m ModeratorTabModel) viewReports() string {
+	doc := strings.Builder{}
+	if len(m.reports) > 0 {
+		doc.WriteString(m.reportTable.View())
+	} else {
+		doc.WriteString(m.quitStyle.Render("No reports"))
+	}
+	doc.WriteString("\n")
+	doc.WriteString(m.quitStyle.Render("enter: open post • delete: remove post • x: dismiss • r: refresh • tab: next view"))
+	return doc.String()
+}
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"database/sql"
 	"strconv"
 	"strings"
@@ -8,16 +9,11 @@ import (
 	"github.com/charmbracelet/bubbles/table"
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/charmbracelet/lipgloss"
-	"github.com/charmbracelet/log"
 )
 
-func getModeratorTab(renderer *lipgloss.Renderer, db *sql.DB) (Tab) {
+func getModeratorTab(parent context.Context, renderer *lipgloss.Renderer, db *sql.DB, user SavedUser) (Tab) {
 	txtStyle := renderer.NewStyle().Foreground(lipgloss.Color("10"))
 	quitStyle := renderer.NewStyle().Foreground(lipgloss.Color("8"))
-	unverifiedUsers, err :=  GetUnverifiedUsers(db)
-	if (err != nil) {
-		log.Debug("Error while fetching users")
-	}
 
 
 	columns := []table.Column{
@@ -27,14 +23,8 @@ func getModeratorTab(renderer *lipgloss.Renderer, db *sql.DB) (Tab) {
 		{Title: "Birth date", Width: 10},
 	}
 
-	var rows []table.Row = make([]table.Row, 0, len(unverifiedUsers))
-	for _, user  := range unverifiedUsers {
-		rows = append(rows, table.Row{strconv.Itoa(int(user.id)), user.username, user.email, ""})
-		
-	}
 	table := table.New(
 		table.WithColumns(columns),
-		table.WithRows(rows),
 		table.WithFocused(true),
 		table.WithHeight(10),
 	)
@@ -43,16 +33,34 @@ func getModeratorTab(renderer *lipgloss.Renderer, db *sql.DB) (Tab) {
 	prefixStyle := renderer.NewStyle().
 			Foreground(lipgloss.Color("#1da1f2"))
 
+	ctx, cancel := context.WithCancel(parent)
+
+	loader := CreateLoader(renderer)
+	loader.Start("Loading users...")
+
 	return Tab{
 		Model: ModeratorTabModel{ 
+			id: nextTabId(),
+			ctx: ctx,
+			cancel: cancel,
 			txtStyle: txtStyle, 
 			quitStyle: quitStyle,
 			prefixStyle: prefixStyle,
 			viewName: "Waiting for verification",
-			users: unverifiedUsers,
 			current: 0,
 			db: db,
+			user: user,
 			table: table,
+			view: usersView,
+			hookTable: createWebhookTable(),
+			deliveryTable: createDeliveryTable(),
+			urlInput: createWebhookUrlInput(renderer),
+			reportTable: createReportTable(),
+			announcementTable: createAnnouncementTable(),
+			announceInput: createAnnouncementInput(renderer),
+			expiryInput: CreateCustomInput(renderer, "Expires in", "never", expiryValidator, false),
+			banner: CreateErrorBanner(renderer),
+			loader: loader,
 		},
 	Name: "Mod",
 	}
@@ -60,6 +68,9 @@ func getModeratorTab(renderer *lipgloss.Renderer, db *sql.DB) (Tab) {
 
 
 type ModeratorTabModel struct {
+	id           int64
+	ctx          context.Context
+	cancel       context.CancelFunc
 	txtStyle     lipgloss.Style
 	quitStyle    lipgloss.Style
 	prefixStyle  lipgloss.Style
@@ -67,17 +78,95 @@ type ModeratorTabModel struct {
 	users        []SavedUser
 	current      int
 	db           *sql.DB
+	user         SavedUser
 	table        table.Model
+	view         ModeratorView
+	hooks        []Webhook
+	hookTable    table.Model
+	revealedHook int64
+	deliveries   []WebhookDelivery
+	deliveryTable table.Model
+	urlInput     CustomInput
+	adding       bool
+	event        int
+	reports      []Report
+	reportTable  table.Model
+	announcements []Announcement
+	announcementTable table.Model
+	announceInput CustomInput
+	expiryInput  CustomInput
+	announcing   bool
+	severity     int
+	confirmReadOnly bool
+	banner       ErrorBanner
+	loader       Loader
+}
+
+type ModeratorView int
+
+const (
+	usersView ModeratorView = iota
+	reportsView
+	webhooksView
+	deliveriesView
+	announcementsView
+	moderatorViews
+)
+
+func (m ModeratorTabModel) switchView() (ModeratorTabModel, tea.Cmd) {
+	m.view = (m.view + 1) % moderatorViews
+	m.banner.Clear()
+	switch m.view {
+	case usersView:
+		m.viewName = "Waiting for verification"
+		return m, nil
+	case reportsView:
+		m.viewName = "Reported posts"
+		cmd := tea.Batch(m.loader.Start("Loading reports..."), loadReports(m.ctx, m.id, m.db))
+		return m, cmd
+	case webhooksView:
+		m.viewName = "Webhooks"
+		cmd := tea.Batch(m.loader.Start("Loading webhooks..."), loadWebhooks(m.ctx, m.id, m.db))
+		return m, cmd
+	case deliveriesView:
+		m.viewName = "Webhook deliveries"
+		cmd := tea.Batch(m.loader.Start("Loading deliveries..."), loadDeliveries(m.ctx, m.id, m.db))
+		return m, cmd
+	default:
+		m.viewName = "Announcements"
+		cmd := tea.Batch(m.loader.Start("Loading announcements..."), loadModAnnouncements(m.ctx, m.id, m.db))
+		return m, cmd
+	}
+}
+
+type UnverifiedUsersMsg struct {
+	tab    int64
+	users  []SavedUser
+	err    error
+}
+
+func loadUnverifiedUsers(ctx context.Context, tab int64, db *sql.DB) tea.Cmd {
+	return func() tea.Msg {
+		if ctx.Err() != nil {
+			return nil
+		}
+		users, err := GetUnverifiedUsers(ctx, db)
+		return UnverifiedUsersMsg{tab: tab, users: users, err: err}
+	}
 }
 
 func (m ModeratorTabModel) Init() tea.Cmd {
-	return nil
+	return tea.Batch(m.loader.Tick(), loadUnverifiedUsers(m.ctx, m.id, m.db))
+}
+
+func (m ModeratorTabModel) Close() {
+	m.cancel()
 }
 
 func (m ModeratorTabModel) GetCurrentIndex() int {
-	username := m.table.SelectedRow()[1]
+	id := m.table.SelectedRow()[0]
 	for i, user := range m.users {
-		if username == user.username {
+		if id == strconv.Itoa(int(user.id)) {
 			return i
 		}
 	}
@@ -100,53 +189,198 @@ func (m *ModeratorTabModel) RemoveFromList(username string) {
 		return
 	}
 	m.users = append(m.users[:current], m.users[current+1:]...)
+	m.SetUsers(m.users)
+}
+
+func (m *ModeratorTabModel) SetUsers(users []SavedUser) {
+	m.users = users
 	var rows []table.Row = make([]table.Row, 0, len(m.users))
 	for _, user  := range m.users {
-		rows = append(rows, table.Row{strconv.Itoa(int(user.id)), user.username, user.email, ""})
+		rows = append(rows, table.Row{strconv.Itoa(int(user.id)), SanitizeLine(user.username), SanitizeLine(user.email), ""})
 	}
 	m.table.SetRows(rows)
 }
 
 type DeleteUserMsg struct {
+	tab      int64
 	username string
 }
 
+type ModeratorErrorMsg struct {
+	tab     int64
+	message string
+	err     error
+}
+
 func (m ModeratorTabModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
-	var cmd tea.Cmd
+	var cmds []tea.Cmd = make([]tea.Cmd, 2)
 	switch msg := msg.(type) {
 	case tea.KeyMsg:
+		if m.adding {
+			return m.updateWebhookForm(msg)
+		}
+		if m.announcing {
+			return m.updateAnnouncementForm(msg)
+		}
+		if m.confirmReadOnly {
+			m.confirmReadOnly = false
+			if msg.String() == "y" {
+				SetReadOnly(!ReadOnly(), m.user.username)
+			}
+			return m, nil
+		}
+		if msg.String() == "tab" {
+			return m.switchView()
+		}
+		var cmd tea.Cmd
+		handled := false
+		switch m.view {
+		case reportsView:
+			m, cmd, handled = m.updateReports(msg)
+		case webhooksView:
+			m, cmd, handled = m.updateWebhooks(msg)
+		case deliveriesView:
+			m, cmd, handled = m.updateDeliveries(msg)
+		case announcementsView:
+			m, cmd, handled = m.updateAnnouncements(msg)
+		}
+		if handled {
+			return m, cmd
+		}
 		switch msg.String() {
 		case "q", "ctrl+c":
 			return m, tea.Quit
+		case "M":
+			m.confirmReadOnly = true
+			return m, nil
 		case "enter":
+			if m.view != usersView {
+				break
+			}
 			if len(m.users) > 0 {
 				user, found := m.GetCurrentChoice()
 				if !found {
 					break
 				}
-				return m, func() tea.Msg {
-					AcceptUser(m.db, user)
-					return DeleteUserMsg{user.username}
-				}
+				ctx, tab, db := m.ctx, m.id, m.db
+				cmd := tea.Batch(m.loader.Start("Saving..."), func() tea.Msg {
+					if ctx.Err() != nil {
+						return nil
+					}
+					if err := AcceptUser(ctx, db, user); err != nil {
+						return ModeratorErrorMsg{tab, "Could not verify " + user.username, err}
+					}
+					return DeleteUserMsg{tab, user.username}
+				})
+				return m, cmd
 			}
 		case "delete":
+			if m.view != usersView {
+				break
+			}
 			if len(m.users) > 0 {
 				user, found := m.GetCurrentChoice()
 				if !found {
 					break
 				}
-				return m, func() tea.Msg {
-					DeleteUser(m.db, user)
-					return DeleteUserMsg{user.username}
-				}
+				ctx, tab, db := m.ctx, m.id, m.db
+				cmd := tea.Batch(m.loader.Start("Saving..."), func() tea.Msg {
+					if ctx.Err() != nil {
+						return nil
+					}
+					if err := DeleteAccount(ctx, db, user); err != nil {
+						return ModeratorErrorMsg{tab, "Could not delete " + user.username, err}
+					}
+					return DeleteUserMsg{tab, user.username}
+				})
+				return m, cmd
 			}
 		}
+	case UnverifiedUsersMsg:
+		if msg.tab != m.id {
+			return m, nil
+		}
+		m.loader.Done()
+		if msg.err != nil {
+			m.banner.Show("Could not load users waiting for verification", msg.err)
+			return m, nil
+		}
+		m.SetUsers(msg.users)
+		return m, nil
+	case ReportsMsg:
+		if msg.tab != m.id {
+			return m, nil
+		}
+		m.loader.Done()
+		if msg.err != nil {
+			m.banner.Show("Could not load reports", msg.err)
+			return m, nil
+		}
+		m.SetReports(msg.reports)
+		return m, nil
+	case WebhooksMsg:
+		if msg.tab != m.id {
+			return m, nil
+		}
+		m.loader.Done()
+		if msg.err != nil {
+			m.banner.Show("Could not load webhooks", msg.err)
+			return m, nil
+		}
+		m.SetWebhooks(msg.hooks)
+		return m, nil
+	case DeliveriesMsg:
+		if msg.tab != m.id {
+			return m, nil
+		}
+		m.loader.Done()
+		if msg.err != nil {
+			m.banner.Show("Could not load deliveries", msg.err)
+			return m, nil
+		}
+		m.SetDeliveries(msg.deliveries)
+		return m, nil
+	case ModAnnouncementsMsg:
+		if msg.tab != m.id {
+			return m, nil
+		}
+		m.loader.Done()
+		if msg.err != nil {
+			m.banner.Show("Could not load announcements", msg.err)
+			return m, nil
+		}
+		m.SetAnnouncements(msg.announcements)
+		return m, nil
 	case DeleteUserMsg:
+		if msg.tab != m.id {
+			return m, nil
+		}
+		m.loader.Done()
+		m.banner.Clear()
 		m.RemoveFromList(msg.username)
 		return m, nil
+	case ModeratorErrorMsg:
+		if msg.tab != m.id {
+			return m, nil
+		}
+		m.loader.Done()
+		m.banner.Show(SanitizeLine(msg.message), msg.err)
+		return m, nil
+	}
+	m.loader, cmds[0] = m.loader.Update(msg)
+	switch m.view {
+	case reportsView:
+		m.reportTable, cmds[1] = m.reportTable.Update(msg)
+	case webhooksView:
+		m.hookTable, cmds[1] = m.hookTable.Update(msg)
+	case deliveriesView:
+		m.deliveryTable, cmds[1] = m.deliveryTable.Update(msg)
+	case announcementsView:
+		m.announcementTable, cmds[1] = m.announcementTable.Update(msg)
+	default:
+		m.table, cmds[1] = m.table.Update(msg)
 	}
-	m.table, cmd = m.table.Update(msg)
-	return m, cmd
+	return m, tea.Batch(cmds...)
 }
 
 func (m ModeratorTabModel) GetCurrentChoice() (SavedUser, bool) {
@@ -154,9 +388,9 @@ func (m ModeratorTabModel) GetCurrentChoice() (SavedUser, bool) {
 	if len(current) < 2 {
 		return SavedUser{}, false
 	}
-	username := current[1]
+	id := current[0]
 	for _, user := range m.users {
-		if username == user.username {
+		if id == strconv.Itoa(int(user.id)) {
 			return user, true
 		}
 	}
@@ -169,12 +403,39 @@ func (m ModeratorTabModel) View() string {
 	doc.WriteString(tabName)
 	doc.WriteString("\n")
 	doc.WriteString(m.viewName)
-	doc.WriteString("\n\n")
-
-	if len(m.users) > 0 {
-		doc.WriteString(m.table.View())
+	if ReadOnly() {
+		doc.WriteString(m.quitStyle.Render(" • read-only mode on (M: turn off)"))
 	} else {
-		doc.WriteString(m.quitStyle.Render("No users"))
+		doc.WriteString(m.quitStyle.Render(" • M: read-only mode"))
+	}
+	doc.WriteString("\n")
+	if m.confirmReadOnly {
+		if ReadOnly() {
+			doc.WriteString(m.prefixStyle.Render("Turn read-only mode off? y: confirm • any key: cancel"))
+		} else {
+			doc.WriteString(m.prefixStyle.Render("Turn read-only mode on for every session? y: confirm • any key: cancel"))
+		}
+		doc.WriteString("\n")
+	}
+	doc.WriteString(m.loader.View())
+	doc.WriteString(m.banner.View())
+	doc.WriteString("\n")
+
+	switch m.view {
+	case reportsView:
+		doc.WriteString(m.viewReports())
+	case webhooksView:
+		doc.WriteString(m.viewWebhooks())
+	case deliveriesView:
+		doc.WriteString(m.viewDeliveries())
+	case announcementsView:
+		doc.WriteString(m.viewAnnouncements())
+	default:
+		if len(m.users) > 0 {
+			doc.WriteString(m.table.View())
+		} else {
+			doc.WriteString(m.quitStyle.Render("No users"))
+		}
 	}
 
 
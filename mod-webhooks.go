@@ -0,0 +1,305 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/charmbracelet/bubbles/table"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+const deliveryLogSize = 50
+
+func webhookUrlValidator(s string) error {
+	u, err := url.Parse(s)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return errors.New("Must be an http(s) URL")
+	}
+	return nil
+}
+
+func createWebhookTable() table.Model {
+	columns := []table.Column{
+		{Title: "Id", Width: 3},
+		{Title: "Event", Width: 15},
+		{Title: "URL", Width: 30},
+		{Title: "Secret", Width: 12},
+	}
+	return table.New(
+		table.WithColumns(columns),
+		table.WithFocused(true),
+		table.WithHeight(10),
+	)
+}
+
+func createDeliveryTable() table.Model {
+	columns := []table.Column{
+		{Title: "Id", Width: 5},
+		{Title: "Event", Width: 15},
+		{Title: "URL", Width: 25},
+		{Title: "Status", Width: 9},
+		{Title: "Tries", Width: 5},
+		{Title: "Result", Width: 30},
+	}
+	return table.New(
+		table.WithColumns(columns),
+		table.WithFocused(true),
+		table.WithHeight(10),
+	)
+}
+
+func createWebhookUrlInput(renderer *lipgloss.Renderer) CustomInput {
+	input := CreateCustomInput(renderer, "URL", "https://example.com/hook", webhookUrlValidator, false)
+	input.Input.CharLimit = 300
+	input.Input.Width = 50
+	return input
+}
+
+type WebhooksMsg struct {
+	tab    int64
+	hooks  []Webhook
+	err    error
+}
+
+func loadWebhooks(ctx context.Context, tab int64, db *sql.DB) tea.Cmd {
+	return func() tea.Msg {
+		if ctx.Err() != nil {
+			return nil
+		}
+		hooks, err := FindWebhooks(ctx, db)
+		return WebhooksMsg{tab: tab, hooks: hooks, err: err}
+	}
+}
+
+type DeliveriesMsg struct {
+	tab         int64
+	deliveries  []WebhookDelivery
+	err         error
+}
+
+func loadDeliveries(ctx context.Context, tab int64, db *sql.DB) tea.Cmd {
+	return func() tea.Msg {
+		if ctx.Err() != nil {
+			return nil
+		}
+		deliveries, err := FindRecentDeliveries(ctx, db, deliveryLogSize)
+		return DeliveriesMsg{tab: tab, deliveries: deliveries, err: err}
+	}
+}
+
+func saveWebhook(ctx context.Context, tab int64, db *sql.DB, hookUrl string, event WebhookEvent) tea.Cmd {
+	return func() tea.Msg {
+		if ctx.Err() != nil {
+			return nil
+		}
+		secret, err := NewWebhookSecret()
+		if err == nil {
+			_, err = SaveWebhook(ctx, db, hookUrl, event, secret)
+		}
+		if err != nil {
+			return ModeratorErrorMsg{tab, "Could not save webhook", err}
+		}
+		return loadWebhooks(ctx, tab, db)()
+	}
+}
+
+func deleteWebhook(ctx context.Context, tab int64, db *sql.DB, id int64) tea.Cmd {
+	return func() tea.Msg {
+		if ctx.Err() != nil {
+			return nil
+		}
+		if err := DeleteWebhook(ctx, db, id); err != nil {
+			return ModeratorErrorMsg{tab, "Could not delete webhook", err}
+		}
+		return loadWebhooks(ctx, tab, db)()
+	}
+}
+
+func retryDelivery(ctx context.Context, tab int64, db *sql.DB, id int64) tea.Cmd {
+	return func() tea.Msg {
+		if ctx.Err() != nil {
+			return nil
+		}
+		if err := RetryDelivery(ctx, db, id); err != nil {
+			return ModeratorErrorMsg{tab, "Only failed deliveries can be retried", err}
+		}
+		return loadDeliveries(ctx, tab, db)()
+	}
+}
+
+func (m *ModeratorTabModel) SetWebhooks(hooks []Webhook) {
+	m.hooks = hooks
+	rows := make([]table.Row, 0, len(hooks))
+	for _, hook := range hooks {
+		rows = append(rows, table.Row{strconv.FormatInt(hook.id, 10), string(hook.event), SanitizeLine(hook.url), maskSecret(hook.secret)})
+	}
+	m.hookTable.SetRows(rows)
+	m.revealedHook = 0
+}
+
+// maskSecret keeps only the start of a secret, enough to tell
+// webhooks apart.
+func maskSecret(secret string) string {
+	if len(secret) <= 4 {
+		return "••••••••"
+	}
+	return secret[:4] + "••••••••"
+}
+
+func (m *ModeratorTabModel) SetDeliveries(deliveries []WebhookDelivery) {
+	m.deliveries = deliveries
+	rows := make([]table.Row, 0, len(deliveries))
+	for _, d := range deliveries {
+		result := ""
+		if d.statusCode.Valid {
+			result = strconv.FormatInt(d.statusCode.Int64, 10) + " "
+		}
+		if d.lastError.Valid {
+			result += d.lastError.String
+		}
+		if d.status == deliveryPending && d.attempts > 0 {
+			result = "retry " + d.nextAttemptAt.Format("15:04:05") + " " + result
+		}
+		rows = append(rows, table.Row{
+			strconv.FormatInt(d.id, 10),
+			string(d.event),
+			SanitizeLine(d.url),
+			string(d.status),
+			strconv.Itoa(d.attempts),
+			SanitizeLine(result),
+		})
+	}
+	m.deliveryTable.SetRows(rows)
+}
+
+func selectedId(t table.Model) (int64, bool) {
+	row := t.SelectedRow()
+	if len(row) == 0 {
+		return 0, false
+	}
+	id, err := strconv.ParseInt(row[0], 10, 64)
+	return id, err == nil
+}
+
+// updateWebhookForm handles keys while a new webhook is typed.
+func (m ModeratorTabModel) updateWebhookForm(msg tea.KeyMsg) (ModeratorTabModel, tea.Cmd) {
+	switch msg.String() {
+	case "esc":
+		m.adding = false
+		m.urlInput.Blur()
+		return m, nil
+	case "up":
+		m.event = (m.event + len(webhookEvents) - 1) % len(webhookEvents)
+		return m, nil
+	case "down":
+		m.event = (m.event + 1) % len(webhookEvents)
+		return m, nil
+	case "enter":
+		value := strings.TrimSpace(m.urlInput.Input.Value())
+		if webhookUrlValidator(value) != nil {
+			return m, nil
+		}
+		m.adding = false
+		m.urlInput.Blur()
+		m.urlInput.Input.SetValue("")
+		cmd := tea.Batch(m.loader.Start("Saving..."), saveWebhook(m.ctx, m.id, m.db, value, webhookEvents[m.event]))
+		return m, cmd
+	}
+	var cmd tea.Cmd
+	m.urlInput, cmd = m.urlInput.Update(msg)
+	return m, cmd
+}
+
+func (m ModeratorTabModel) updateWebhooks(msg tea.KeyMsg) (ModeratorTabModel, tea.Cmd, bool) {
+	switch msg.String() {
+	case "a":
+		m.adding = true
+		cmd := m.urlInput.Focus()
+		return m, cmd, true
+	case "r":
+		cmd := tea.Batch(m.loader.Start("Loading webhooks..."), loadWebhooks(m.ctx, m.id, m.db))
+		return m, cmd, true
+	case "s":
+		id, ok := selectedId(m.hookTable)
+		if !ok || m.revealedHook == id {
+			m.revealedHook = 0
+		} else {
+			m.revealedHook = id
+		}
+		return m, nil, true
+	case "delete":
+		id, ok := selectedId(m.hookTable)
+		if !ok {
+			return m, nil, true
+		}
+		cmd := tea.Batch(m.loader.Start("Deleting..."), deleteWebhook(m.ctx, m.id, m.db, id))
+		return m, cmd, true
+	}
+	return m, nil, false
+}
+
+func (m ModeratorTabModel) updateDeliveries(msg tea.KeyMsg) (ModeratorTabModel, tea.Cmd, bool) {
+	switch msg.String() {
+	case "r":
+		cmd := tea.Batch(m.loader.Start("Loading deliveries..."), loadDeliveries(m.ctx, m.id, m.db))
+		return m, cmd, true
+	case "enter":
+		id, ok := selectedId(m.deliveryTable)
+		if !ok {
+			return m, nil, true
+		}
+		cmd := tea.Batch(m.loader.Start("Retrying..."), retryDelivery(m.ctx, m.id, m.db, id))
+		return m, cmd, true
+	}
+	return m, nil, false
+}
+
+func (m ModeratorTabModel) viewWebhooks() string {
+	doc := strings.Builder{}
+	if m.adding {
+		doc.WriteString(m.urlInput.View(true))
+		doc.WriteString("\n")
+		for i, event := range webhookEvents {
+			if i == m.event {
+				doc.WriteString(m.prefixStyle.Render("⍟ "))
+			} else {
+				doc.WriteString("  ")
+			}
+			doc.WriteString(string(event))
+			doc.WriteString("\n")
+		}
+		doc.WriteString(m.quitStyle.Render("up/down: event • enter: save • esc: cancel"))
+		return doc.String()
+	}
+	if len(m.hooks) > 0 {
+		doc.WriteString(m.hookTable.View())
+	} else {
+		doc.WriteString(m.quitStyle.Render("No webhooks"))
+	}
+	doc.WriteString("\n")
+	for _, hook := range m.hooks {
+		if hook.id == m.revealedHook {
+			doc.WriteString("Secret of #" + strconv.FormatInt(hook.id, 10) + ": " + hook.secret)
+			doc.WriteString("\n")
+		}
+	}
+	doc.WriteString(m.quitStyle.Render("a: add • delete: remove • s: show secret • r: refresh • tab: next view"))
+	return doc.String()
+}
+
+func (m ModeratorTabModel) viewDeliveries() string {
+	doc := strings.Builder{}
+	if len(m.deliveries) > 0 {
+		doc.WriteString(m.deliveryTable.View())
+	} else {
+		doc.WriteString(m.quitStyle.Render("No deliveries"))
+	}
+	doc.WriteString("\n")
+	doc.WriteString(m.quitStyle.Render("enter: retry failed • r: refresh • tab: next view"))
+	return doc.String()
+}
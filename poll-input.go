@@ -0,0 +1,192 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/bubbles/textinput"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+const (
+	minPollOptions = 2
+	maxPollOptions = 4
+	maxPollDuration = 7 * 24 * time.Hour
+)
+
+type PollInput struct {
+	options          []textinput.Model
+	duration         textinput.Model
+	current          int
+	labelStyle       lipgloss.Style
+	prefixStyle      lipgloss.Style
+}
+
+func CreatePollInput(renderer *lipgloss.Renderer) PollInput {
+	options := make([]textinput.Model, maxPollOptions)
+	for i := range options {
+		input := textinput.New()
+		input.Placeholder = fmt.Sprintf("Option %d", i+1)
+		input.Prompt = ""
+		input.CharLimit = 40
+		input.Width = 25
+		options[i] = input
+	}
+
+	duration := textinput.New()
+	duration.Placeholder = "1d"
+	duration.Prompt = ""
+	duration.CharLimit = 5
+	duration.Width = 5
+	duration.Validate = pollDurationValidator
+
+	labelStyle := renderer.NewStyle().Foreground(lipgloss.Color("8"))
+	prefixStyle := renderer.NewStyle().
+			Foreground(lipgloss.Color("#1da1f2"))
+
+	return PollInput{
+		options: options,
+		duration: duration,
+		current: -1,
+		labelStyle: labelStyle,
+		prefixStyle: prefixStyle,
+	}
+}
+
+func (p PollInput) Focused() bool {
+	return p.current >= 0
+}
+
+func (p *PollInput) Blur() {
+	for i := range p.options {
+		p.options[i].Blur()
+	}
+	p.duration.Blur()
+	p.current = -1
+}
+
+func (p *PollInput) focus(index int) (bool, tea.Cmd) {
+	if index < 0 || index > len(p.options) {
+		p.current = -1
+		return false, nil
+	}
+	p.current = index
+	if index == len(p.options) {
+		return true, p.duration.Focus()
+	}
+	return true, p.options[index].Focus()
+}
+
+// Next moves focus to the following field and reports false when
+// focus leaves the poll, so the caller can hand it back to its own input.
+func (p *PollInput) Next() (bool, tea.Cmd) {
+	next := p.current + 1
+	p.Blur()
+	return p.focus(next)
+}
+
+func (p *PollInput) Reset() {
+	p.Blur()
+	for i := range p.options {
+		p.options[i].Reset()
+	}
+	p.duration.Reset()
+}
+
+func (p PollInput) Update(msg tea.Msg) (PollInput, tea.Cmd) {
+	var cmd tea.Cmd
+	if p.current < 0 {
+		return p, nil
+	}
+	if p.current == len(p.options) {
+		p.duration, cmd = p.duration.Update(msg)
+	} else {
+		p.options[p.current], cmd = p.options[p.current].Update(msg)
+	}
+	return p, cmd
+}
+
+func (p PollInput) Options() []string {
+	options := make([]string, 0, len(p.options))
+	for _, input := range p.options {
+		option := strings.TrimSpace(input.Value())
+		if option != "" {
+			options = append(options, option)
+		}
+	}
+	return options
+}
+
+func (p PollInput) Duration() (time.Duration, error) {
+	value := p.duration.Value()
+	if value == "" {
+		value = p.duration.Placeholder
+	}
+	return parsePollDuration(value)
+}
+
+func (p PollInput) Validate() error {
+	if len(p.Options()) < minPollOptions {
+		return fmt.Errorf("poll needs at least %d options", minPollOptions)
+	}
+	_, err := p.Duration()
+	return err
+}
+
+func (p PollInput) getPrefix(current bool) string {
+	if current {
+		return p.prefixStyle.Render("⍟ ")
+	}
+	return "  "
+}
+
+func (p PollInput) View() string {
+	doc := strings.Builder{}
+	doc.WriteString(p.labelStyle.Render("Poll (tab: next field, ctrl+o: remove)"))
+	for i, input := range p.options {
+		doc.WriteString("\n")
+		doc.WriteString(p.getPrefix(p.current == i))
+		doc.WriteString(input.View())
+	}
+	doc.WriteString("\n")
+	doc.WriteString(p.getPrefix(p.current == len(p.options)))
+	doc.WriteString(p.labelStyle.Render("Duration: "))
+	doc.WriteString(p.duration.View())
+	return doc.String()
+}
+
+// parsePollDuration accepts Go durations ("90m", "12h") and whole days ("3d").
+func parsePollDuration(s string) (time.Duration, error) {
+	var duration time.Duration
+	if days, found := strings.CutSuffix(s, "d"); found {
+		n, err := strconv.Atoi(days)
+		if err != nil {
+			return 0, fmt.Errorf("format: 30m, 12h or 3d")
+		}
+		duration = time.Duration(n) * 24 * time.Hour
+	} else {
+		var err error
+		duration, err = time.ParseDuration(s)
+		if err != nil {
+			return 0, fmt.Errorf("format: 30m, 12h or 3d")
+		}
+	}
+	if duration < 5*time.Minute {
+		return 0, fmt.Errorf("at least 5 minutes")
+	}
+	if duration > maxPollDuration {
+		return 0, fmt.Errorf("at most 7 days")
+	}
+	return duration, nil
+}
+
+func pollDurationValidator(s string) error {
+	if len(s) == 0 {
+		return nil
+	}
+	_, err := parsePollDuration(s)
+	return err
+}
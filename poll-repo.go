@@ -0,0 +1,259 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/charmbracelet/log"
+	"github.com/lib/pq"
+)
+
+type Poll struct {
+	id              int64
+	postId          int64
+	endsAt          time.Time
+	options         []PollOption
+	votes           int
+	choice          sql.NullInt64
+}
+
+type PollOption struct {
+	id              int64
+	pollId          int64
+	position        int
+	content         string
+	votes           int
+}
+
+func (p Poll) Closed() bool {
+	return time.Now().After(p.endsAt)
+}
+
+func CreatePollTable(db *sql.DB) {
+	createTableSQL := `
+	CREATE TABLE IF NOT EXISTS polls (
+		id SERIAL PRIMARY KEY,
+		post_id INTEGER UNIQUE REFERENCES posts(id) ON DELETE CASCADE,
+		ends_at TIMESTAMP WITH TIME ZONE NOT NULL
+	);`
+
+	_, err := db.Exec(createTableSQL)
+	if err != nil {
+		log.Fatalf("Failed to create table: %v", err)
+	}
+
+	log.Info("Table 'polls' created successfully!")
+}
+
+func CreatePollOptionTable(db *sql.DB) {
+	createTableSQL := `
+	CREATE TABLE IF NOT EXISTS poll_options (
+		id SERIAL PRIMARY KEY,
+		poll_id INTEGER REFERENCES polls(id) ON DELETE CASCADE,
+		position INTEGER NOT NULL,
+		content VARCHAR(40) NOT NULL,
+		votes INTEGER DEFAULT 0
+	);`
+
+	_, err := db.Exec(createTableSQL)
+	if err != nil {
+		log.Fatalf("Failed to create table: %v", err)
+	}
+
+	log.Info("Table 'poll_options' created successfully!")
+}
+
+func CreatePollVoteTable(db *sql.DB) {
+	createTableSQL := `
+	CREATE TABLE IF NOT EXISTS poll_votes (
+		id SERIAL PRIMARY KEY,
+		user_id INTEGER REFERENCES users(id),
+		poll_id INTEGER REFERENCES polls(id) ON DELETE CASCADE,
+		option_id INTEGER REFERENCES poll_options(id) ON DELETE CASCADE,
+		voted_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+		CONSTRAINT unique_vote UNIQUE (user_id, poll_id)
+	);`
+
+	_, err := db.Exec(createTableSQL)
+	if err != nil {
+		log.Fatalf("Failed to create table: %v", err)
+	}
+
+	log.Info("Table 'poll_votes' created successfully!")
+}
+
+func CreateVoteFunction(db *sql.DB) {
+	query := `
+	CREATE OR REPLACE PROCEDURE add_vote(user_id_param INTEGER, poll_id_param INTEGER, option_id_param INTEGER)
+	LANGUAGE plpgsql
+	AS $$
+	BEGIN
+		IF NOT EXISTS (
+			SELECT 1 FROM polls WHERE id = poll_id_param AND ends_at > CURRENT_TIMESTAMP
+		) THEN
+		        RAISE EXCEPTION 'Poll closed' USING ERRCODE = 'P0001';
+		END IF;
+
+		IF NOT EXISTS (
+			SELECT 1 FROM poll_options WHERE id = option_id_param AND poll_id = poll_id_param
+		) THEN
+		        RAISE EXCEPTION 'Unknown option' USING ERRCODE = 'P0001';
+		END IF;
+
+		INSERT INTO poll_votes (user_id, poll_id, option_id)
+		VALUES (user_id_param, poll_id_param, option_id_param);
+
+		UPDATE poll_options SET votes = votes + 1
+		WHERE id = option_id_param AND poll_id = poll_id_param;
+	END;
+	$$;`
+
+	_, err := db.Exec(query)
+	if err != nil {
+		log.Fatalf("Failed to create procedure for inserting votes: %v", err)
+	}
+
+	log.Info("Procedure created successfully!")
+}
+
+func SavePostWithPoll(ctx context.Context, db *sql.DB, user SavedUser, content string, contentWarning string, options []string, duration time.Duration) (int64, error) {
+	if err := checkWritable(); err != nil {
+		return 0, err
+	}
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+	log.Info("Saving post with poll to db")
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		log.Errorf("failed to start transaction: %v", err)
+		return 0, fmt.Errorf("failed to start transaction: %w", mapDbError(err))
+	}
+	defer tx.Rollback()
+
+	var postId int64
+	query := `INSERT INTO posts (content, user_id, content_warning)
+        VALUES ($1, $2, NULLIF($3, ''))
+	RETURNING id`
+	err = tx.QueryRowContext(ctx, query, Sanitize(content), user.id, Sanitize(contentWarning)).Scan(&postId)
+	if err != nil {
+		log.Errorf("failed to insert post: %v", err)
+		return 0, fmt.Errorf("failed to insert post: %w", mapDbError(err))
+	}
+
+	var pollId int64
+	query = `INSERT INTO polls (post_id, ends_at)
+        VALUES ($1, $2)
+	RETURNING id`
+	err = tx.QueryRowContext(ctx, query, postId, time.Now().Add(duration)).Scan(&pollId)
+	if err != nil {
+		log.Errorf("failed to insert poll: %v", err)
+		return 0, fmt.Errorf("failed to insert poll: %w", mapDbError(err))
+	}
+
+	query = `INSERT INTO poll_options (poll_id, position, content) VALUES ($1, $2, $3)`
+	for i, option := range options {
+		_, err = tx.ExecContext(ctx, query, pollId, i, SanitizeLine(option))
+		if err != nil {
+			log.Errorf("failed to insert poll option: %v", err)
+			return 0, fmt.Errorf("failed to insert poll option: %w", mapDbError(err))
+		}
+	}
+
+	if err = tx.Commit(); err != nil {
+		log.Errorf("failed to commit poll: %v", err)
+		return 0, fmt.Errorf("failed to commit poll: %w", mapDbError(err))
+	}
+
+	log.Info("Saved new post with poll")
+	postsCreated.Inc()
+	return postId, nil
+}
+
+func SaveVote(ctx context.Context, db *sql.DB, user SavedUser, poll Poll, option PollOption) error {
+	if err := checkWritable(); err != nil {
+		return err
+	}
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+	log.Info("Saving vote to db")
+	query := `CALL add_vote($1, $2, $3)`
+
+	_, err := db.ExecContext(ctx, query, user.id, poll.id, option.id)
+
+	if err != nil {
+		err = mapDbError(err)
+		if IsDomainError(err) {
+			log.Warn(err)
+			return err
+		}
+		log.Errorf("failed to insert vote: %v", err)
+		return fmt.Errorf("failed to insert vote: %w", err)
+	}
+
+	log.Info("Saved new vote")
+	return nil
+}
+
+func FindPolls(ctx context.Context, db *sql.DB, postIds []int64, viewer SavedUser) (map[int64]*Poll, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+	polls := make(map[int64]*Poll)
+	if len(postIds) == 0 {
+		return polls, nil
+	}
+
+	query := `
+	SELECT pl.id, pl.post_id, pl.ends_at, v.option_id,
+	       o.id, o.position, o.content, o.votes
+	FROM polls pl
+	LEFT JOIN poll_votes v ON pl.id = v.poll_id AND v.user_id = $2
+	INNER JOIN poll_options o ON o.poll_id = pl.id
+	WHERE pl.post_id = ANY($1)
+	ORDER BY pl.id, o.position`
+	rows, err := db.QueryContext(ctx, query, pq.Array(postIds), viewer.id)
+	if err != nil {
+		return nil, mapDbError(err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var poll Poll
+		var option PollOption
+		if err := rows.Scan(&poll.id, &poll.postId, &poll.endsAt, &poll.choice, &option.id, &option.position, &option.content, &option.votes); err != nil {
+			return nil, mapDbError(err)
+		}
+		option.pollId = poll.id
+		saved, ok := polls[poll.postId]
+		if !ok {
+			saved = &poll
+			polls[poll.postId] = saved
+		}
+		saved.options = append(saved.options, option)
+		saved.votes += option.votes
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, mapDbError(err)
+	}
+
+	return polls, nil
+}
+
+func AttachPolls(ctx context.Context, db *sql.DB, posts []Post, viewer SavedUser) error {
+	ids := make([]int64, len(posts))
+	for i, post := range posts {
+		ids[i] = post.id
+	}
+	polls, err := FindPolls(ctx, db, ids, viewer)
+	if err != nil {
+		return err
+	}
+	for i := range posts {
+		if poll, ok := polls[posts[i].id]; ok {
+			posts[i].poll = poll
+		}
+	}
+	return nil
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/log"
+)
+
+type PostsLoadedMsg struct {
+	tab   int64
+	posts []Post
+	err   error
+}
+
+func loadPosts(ctx context.Context, tab int64, db *sql.DB, viewer SavedUser, find FindPostsFunc) tea.Cmd {
+	return func() tea.Msg {
+		if ctx.Err() != nil {
+			return nil
+		}
+		posts, err := find(ctx, db, viewer)
+		if err != nil {
+			return PostsLoadedMsg{tab: tab, err: err}
+		}
+		if err := AttachPolls(ctx, db, posts, viewer); err != nil {
+			log.Error(err)
+		}
+		return PostsLoadedMsg{tab: tab, posts: posts}
+	}
+}
+
+type PostSavedMsg struct {
+	tab  int64
+	post Post
+	err  error
+}
+
+func savePost(ctx context.Context, tab int64, db *sql.DB, user SavedUser, composer Composer) tea.Cmd {
+	return func() tea.Msg {
+		if ctx.Err() != nil {
+			return nil
+		}
+		if err := postLimiter.check(user.username); err != nil {
+			return PostSavedMsg{tab: tab, err: err}
+		}
+		post, err := composer.Save(ctx, db, user)
+		if err == nil {
+			PostCreated(ctx, post.id)
+			NotifyPostCreated(ctx, post.id)
+		}
+		return PostSavedMsg{tab: tab, post: post, err: err}
+	}
+}
+
+type LikeMsg struct {
+	tab    int64
+	postId int64
+	liked  bool
+	err    error
+}
+
+func toggleLike(ctx context.Context, tab int64, db *sql.DB, user SavedUser, post Post) tea.Cmd {
+	return func() tea.Msg {
+		if ctx.Err() != nil {
+			return nil
+		}
+		if err := likeLimiter.check(user.username); err != nil {
+			return LikeMsg{tab: tab, postId: post.id, liked: !post.liked, err: err}
+		}
+		var err error
+		if post.liked {
+			err = DeleteLike(ctx, db, user, post)
+		} else {
+			err = SaveLike(ctx, db, user, post)
+		}
+		return LikeMsg{tab: tab, postId: post.id, liked: !post.liked, err: err}
+	}
+}
+
+type VoteMsg struct {
+	tab    int64
+	postId int64
+	option int
+	err    error
+}
+
+func vote(ctx context.Context, tab int64, db *sql.DB, user SavedUser, post Post, option int) tea.Cmd {
+	return func() tea.Msg {
+		if ctx.Err() != nil {
+			return nil
+		}
+		err := SaveVote(ctx, db, user, *post.poll, post.poll.options[option])
+		return VoteMsg{tab: tab, postId: post.id, option: option, err: err}
+	}
+}
+
+type ReportMsg struct {
+	tab    int64
+	err    error
+}
+
+func reportPost(ctx context.Context, tab int64, db *sql.DB, user SavedUser, post Post) tea.Cmd {
+	return func() tea.Msg {
+		if ctx.Err() != nil {
+			return nil
+		}
+		id, err := SaveReport(ctx, db, user, post)
+		if err == nil {
+			NotifyReportFiled(ctx, id, user, post.id)
+		}
+		return ReportMsg{tab: tab, err: err}
+	}
+}
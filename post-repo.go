@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
 	"time"
@@ -12,12 +13,14 @@ type Post struct {
 	id              int64
 	userId          int64
 	content         string
+	contentWarning  sql.NullString
 	likes           int
 	replies         int
 	username        string
 	createdAt       time.Time
 	liked           bool
 	parentId        sql.NullInt64
+	poll            *Poll
 }
 
 func CreatePostTable(db *sql.DB) {
@@ -29,8 +32,10 @@ func CreatePostTable(db *sql.DB) {
 		likes INTEGER DEFAULT 0,
 		replies INTEGER DEFAULT 0,
 		created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
-		parent_id INTEGER REFERENCES posts(id)
-	);`
+		parent_id INTEGER REFERENCES posts(id),
+		content_warning VARCHAR(100)
+	);
+	ALTER TABLE posts ADD COLUMN IF NOT EXISTS content_warning VARCHAR(100);`
 
 	_, err := db.Exec(createTableSQL)
 	if err != nil {
@@ -40,54 +45,169 @@ func CreatePostTable(db *sql.DB) {
 	log.Info("Table 'posts' created successfully!")
 }
 
-func SavePost(db *sql.DB, user SavedUser, content string) (int64, error) {
+func SavePost(ctx context.Context, db *sql.DB, user SavedUser, content string, contentWarning string) (int64, error) {
+	if err := checkWritable(); err != nil {
+		return 0, err
+	}
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
 	log.Info("Saving post to db")
 	var id int64
-	query := `INSERT INTO posts (content, user_id)
-        VALUES ($1, $2)
+	query := `INSERT INTO posts (content, user_id, content_warning)
+        VALUES ($1, $2, NULLIF($3, ''))
 	RETURNING id`
-	err := db.QueryRow(query, content, user.id).
+	err := db.QueryRowContext(ctx, query, Sanitize(content), user.id, Sanitize(contentWarning)).
 		Scan(&id)
 
 	if err != nil {
 		log.Errorf("failed to insert post: %v", err)
-		return 0, fmt.Errorf("failed to insert post: %v", err)
+		return 0, fmt.Errorf("failed to insert post: %w", mapDbError(err))
 	}
 
 	log.Info("Saved new post")
+	postsCreated.Inc()
 	return id, nil
 }
 
-func DeletePost(db *sql.DB, post Post, user SavedUser) error {
+// DeletePost removes a post of user, like RemovePost does for
+// moderators.
+func DeletePost(ctx context.Context, db *sql.DB, post Post, user SavedUser) error {
 	if user.id != post.userId {
 		log.Errorf("Cannot delete, user %s tried to delete post %d", user.username, post.id)
 		return fmt.Errorf("Cannot delete")
 	}
+	return RemovePost(ctx, db, post.id)
+}
+
+// RemovePost deletes a post for moderation, whoever wrote it.
+// Replies are kept as top-level posts.
+func RemovePost(ctx context.Context, db *sql.DB, id int64) error {
+	if err := checkWritable(); err != nil {
+		return err
+	}
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+	log.Info("Removing post", "id", id)
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		log.Errorf("failed to start transaction: %v", err)
+		return fmt.Errorf("failed to start transaction: %w", mapDbError(err))
+	}
+	defer tx.Rollback()
+
+	steps := []deletionStep{
+		{"decrement replies", `
+		UPDATE posts SET replies = GREATEST(replies - 1, 0)
+		WHERE id = (SELECT parent_id FROM posts WHERE id = $1)`},
+		{"detach replies", `UPDATE posts SET parent_id = NULL WHERE parent_id = $1`},
+	}
+	for _, step := range steps {
+		if _, err := tx.ExecContext(ctx, step.query, id); err != nil {
+			log.Errorf("failed to remove post (%s): %v", step.name, err)
+			return fmt.Errorf("failed to remove post (%s): %w", step.name, mapDbError(err))
+		}
+	}
 
-	query := `DELETE FROM posts WHERE id = $1`
-	result, err := db.Exec(query, post.id)
+	result, err := tx.ExecContext(ctx, `DELETE FROM posts WHERE id = $1`, id)
 	if err != nil {
 		log.Errorf("failed to delete post: %v", err)
-		return fmt.Errorf("failed to delete post: %v", err)
+		return fmt.Errorf("failed to delete post: %w", mapDbError(err))
 	}
 
 	rowsAffected, err := result.RowsAffected()
 	if err != nil {
 		log.Errorf("failed to retrieve affected rows: %v", err)
-		return fmt.Errorf("failed to retrieve affected rows: %v", err)
+		return fmt.Errorf("failed to retrieve affected rows: %w", mapDbError(err))
 	}
 
 	if rowsAffected == 0 {
-		log.Errorf("no post found with id: %d", post.id)
-		return fmt.Errorf("no post found with id: %d", post.id)
+		log.Errorf("no post found with id: %d", id)
+		return ErrNotFound
 	}
 
+	if err = tx.Commit(); err != nil {
+		log.Errorf("failed to commit post removal: %v", err)
+		return fmt.Errorf("failed to commit post removal: %w", mapDbError(err))
+	}
 	return nil
 }
 
-func FindUserPosts(db *sql.DB, user SavedUser, viewer SavedUser) ([]Post, error) {
+// FindAllPostsPage reads one page of FindAllPosts.
+func FindAllPostsPage(ctx context.Context, db *sql.DB, viewer SavedUser, limit int, offset int) ([]Post, error) {
+	query := `
+	SELECT p.id, p.content, p.content_warning, p.user_id, p.created_at, u.username, p.likes, p.replies,
+	       CASE WHEN l.user_id IS NOT NULL THEN TRUE ELSE FALSE END AS liked
+	FROM posts p
+	LEFT JOIN likes l ON p.id = l.post_id AND l.user_id = $1
+	LEFT JOIN users u
+	ON p.user_id = u.id
+	ORDER BY p.created_at DESC, p.id DESC
+	LIMIT $2 OFFSET $3`
+	return findPostsPage(ctx, db, query, viewer.id, limit, offset)
+}
+
+// FindUserPostsPage reads one page of FindUserPosts.
+func FindUserPostsPage(ctx context.Context, db *sql.DB, user SavedUser, viewer SavedUser, limit int, offset int) ([]Post, error) {
+	query := `
+	SELECT p.id, p.content, p.content_warning, p.user_id, p.created_at, u.username, p.likes, p.replies,
+	       CASE WHEN l.user_id IS NOT NULL THEN TRUE ELSE FALSE END AS liked
+	FROM posts p
+	LEFT JOIN likes l ON p.id = l.post_id AND l.user_id = $1
+	LEFT JOIN users u
+	ON p.user_id = u.id
+	WHERE p.user_id = $4
+	ORDER BY p.created_at DESC, p.id DESC
+	LIMIT $2 OFFSET $3`
+	return findPostsPage(ctx, db, query, viewer.id, limit, offset, user.id)
+}
+
+// FindRepliesPage reads one page of FindReplies.
+func FindRepliesPage(ctx context.Context, db *sql.DB, id int64, viewer SavedUser, limit int, offset int) ([]Post, error) {
+	query := `
+	SELECT p.id, p.content, p.content_warning, p.user_id, p.created_at, u.username, p.likes, p.replies,
+	       CASE WHEN l.user_id IS NOT NULL THEN TRUE ELSE FALSE END AS liked
+	FROM posts p
+	LEFT JOIN likes l ON p.id = l.post_id AND l.user_id = $1
+	LEFT JOIN users u
+	ON p.user_id = u.id
+	WHERE p.parent_id = $4
+	ORDER BY p.created_at DESC, p.id DESC
+	LIMIT $2 OFFSET $3`
+	return findPostsPage(ctx, db, query, viewer.id, limit, offset, id)
+}
+
+// findPostsPage runs a paged query taking the viewer, limit and
+// offset as its first parameters.
+func findPostsPage(ctx context.Context, db *sql.DB, query string, args ...any) ([]Post, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+	rows, err := db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, mapDbError(err)
+	}
+	defer rows.Close()
+
+	var posts []Post
+	for rows.Next() {
+		var post Post
+		if err := rows.Scan(&post.id, &post.content, &post.contentWarning, &post.userId, &post.createdAt, &post.username, &post.likes, &post.replies, &post.liked); err != nil {
+			return nil, mapDbError(err)
+		}
+		posts = append(posts, post)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, mapDbError(err)
+	}
+
+	return posts, nil
+}
+
+func FindUserPosts(ctx context.Context, db *sql.DB, user SavedUser, viewer SavedUser) ([]Post, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
 	query := `
-	SELECT p.id, p.content, p.user_id, p.created_at, u.username, p.likes, p.replies,
+	SELECT p.id, p.content, p.content_warning, p.user_id, p.created_at, u.username, p.likes, p.replies,
 	       CASE WHEN l.user_id IS NOT NULL THEN TRUE ELSE FALSE END AS liked
 	FROM posts p
 	LEFT JOIN likes l ON p.id = l.post_id AND l.user_id = $2
@@ -95,62 +215,66 @@ func FindUserPosts(db *sql.DB, user SavedUser, viewer SavedUser) ([]Post, error)
 	ON p.user_id = u.id
 	WHERE p.user_id = $1
 	ORDER BY p.created_at DESC`
-	rows, err := db.Query(query, user.id, viewer.id)
+	rows, err := db.QueryContext(ctx, query, user.id, viewer.id)
 	if err != nil {
-		return nil, err
+		return nil, mapDbError(err)
 	}
 	defer rows.Close()
 
 	var posts []Post
 	for rows.Next() {
 		var post Post
-		if err := rows.Scan(&post.id, &post.content, &post.userId, &post.createdAt, &post.username, &post.likes, &post.replies, &post.liked); err != nil {
-			return nil, err
+		if err := rows.Scan(&post.id, &post.content, &post.contentWarning, &post.userId, &post.createdAt, &post.username, &post.likes, &post.replies, &post.liked); err != nil {
+			return nil, mapDbError(err)
 		}
 		posts = append(posts, post)
 	}
 
 	if err := rows.Err(); err != nil {
-		return nil, err
+		return nil, mapDbError(err)
 	}
 
 	return posts, nil
 }
 
-func FindAllPosts(db *sql.DB, viewer SavedUser) ([]Post, error) {
+func FindAllPosts(ctx context.Context, db *sql.DB, viewer SavedUser) ([]Post, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
 	query := `
-	SELECT p.id, p.content, p.user_id, p.created_at, u.username, p.likes, p.replies,
+	SELECT p.id, p.content, p.content_warning, p.user_id, p.created_at, u.username, p.likes, p.replies,
 	       CASE WHEN l.user_id IS NOT NULL THEN TRUE ELSE FALSE END AS liked
 	FROM posts p
 	LEFT JOIN likes l ON p.id = l.post_id AND l.user_id = $1
 	LEFT JOIN users u
 	ON p.user_id = u.id
 	ORDER BY p.created_at DESC`
-	rows, err := db.Query(query, viewer.id)
+	rows, err := db.QueryContext(ctx, query, viewer.id)
 	if err != nil {
-		return nil, err
+		return nil, mapDbError(err)
 	}
 	defer rows.Close()
 
 	var posts []Post
 	for rows.Next() {
 		var post Post
-		if err := rows.Scan(&post.id, &post.content, &post.userId, &post.createdAt, &post.username, &post.likes, &post.replies, &post.liked); err != nil {
-			return nil, err
+		if err := rows.Scan(&post.id, &post.content, &post.contentWarning, &post.userId, &post.createdAt, &post.username, &post.likes, &post.replies, &post.liked); err != nil {
+			return nil, mapDbError(err)
 		}
 		posts = append(posts, post)
 	}
 
 	if err := rows.Err(); err != nil {
-		return nil, err
+		return nil, mapDbError(err)
 	}
 
 	return posts, nil
 }
 
-func FindFollowedPosts(db *sql.DB, viewer SavedUser) ([]Post, error) {
+func FindFollowedPosts(ctx context.Context, db *sql.DB, viewer SavedUser) ([]Post, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
 	query := `
-	SELECT p.id, p.content, p.user_id, p.created_at, u.username, p.likes, p.replies,
+	SELECT p.id, p.content, p.content_warning, p.user_id, p.created_at, u.username, p.likes, p.replies,
 	       CASE WHEN l.user_id IS NOT NULL THEN TRUE ELSE FALSE END AS liked
 	FROM posts p
 	INNER JOIN follows f ON f.followed_id = p.user_id AND f.user_id = $1
@@ -158,90 +282,95 @@ func FindFollowedPosts(db *sql.DB, viewer SavedUser) ([]Post, error) {
 	LEFT JOIN users u ON p.user_id = u.id
 	WHERE f.user_id = $1
 	ORDER BY p.created_at DESC`
-	rows, err := db.Query(query, viewer.id)
+	rows, err := db.QueryContext(ctx, query, viewer.id)
 	if err != nil {
-		return nil, err
+		return nil, mapDbError(err)
 	}
 	defer rows.Close()
 
 	var posts []Post
 	for rows.Next() {
 		var post Post
-		if err := rows.Scan(&post.id, &post.content, &post.userId, &post.createdAt, &post.username, &post.likes, &post.replies, &post.liked); err != nil {
-			return nil, err
+		if err := rows.Scan(&post.id, &post.content, &post.contentWarning, &post.userId, &post.createdAt, &post.username, &post.likes, &post.replies, &post.liked); err != nil {
+			return nil, mapDbError(err)
 		}
 		posts = append(posts, post)
 	}
 
 	if err := rows.Err(); err != nil {
-		return nil, err
+		return nil, mapDbError(err)
 	}
 
 	return posts, nil
 }
 
-func FindLikedPosts(db *sql.DB, viewer SavedUser) ([]Post, error) {
+func FindLikedPosts(ctx context.Context, db *sql.DB, viewer SavedUser) ([]Post, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
 	query := `
-	SELECT p.id, p.content, p.user_id, p.created_at, u.username, p.likes, p.replies,
+	SELECT p.id, p.content, p.content_warning, p.user_id, p.created_at, u.username, p.likes, p.replies,
 	       CASE WHEN l.user_id IS NOT NULL THEN TRUE ELSE FALSE END AS liked
 	FROM posts p
 	LEFT JOIN likes l ON p.id = l.post_id AND l.user_id = $1
 	LEFT JOIN users u ON p.user_id = u.id
 	WHERE l.user_id IS NOT NULL
 	ORDER BY p.created_at DESC`
-	rows, err := db.Query(query, viewer.id)
+	rows, err := db.QueryContext(ctx, query, viewer.id)
 	if err != nil {
-		return nil, err
+		return nil, mapDbError(err)
 	}
 	defer rows.Close()
 
 	var posts []Post
 	for rows.Next() {
 		var post Post
-		if err := rows.Scan(&post.id, &post.content, &post.userId, &post.createdAt, &post.username, &post.likes, &post.replies, &post.liked); err != nil {
-			return nil, err
+		if err := rows.Scan(&post.id, &post.content, &post.contentWarning, &post.userId, &post.createdAt, &post.username, &post.likes, &post.replies, &post.liked); err != nil {
+			return nil, mapDbError(err)
 		}
 		posts = append(posts, post)
 	}
 
 	if err := rows.Err(); err != nil {
-		return nil, err
+		return nil, mapDbError(err)
 	}
 
 	return posts, nil
 }
 
-func GetPostById(db *sql.DB, id int64, username string) (Post, bool) {
+func GetPostById(ctx context.Context, db *sql.DB, id int64, viewer SavedUser) (Post, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
 	var post Post
 	log.Debug("Fetching post from db")
 	query := `
-	SELECT p.id, p.content, p.user_id, p.created_at, u.username, p.likes, p.replies,
+	SELECT p.id, p.content, p.content_warning, p.user_id, p.created_at, u.username, p.likes, p.replies,
 	       CASE WHEN l.user_id IS NOT NULL THEN TRUE ELSE FALSE END AS liked,
 	       p.parent_id
 	FROM posts p
-	LEFT JOIN likes l ON p.id = l.post_id 
+	LEFT JOIN likes l ON p.id = l.post_id AND l.user_id = $1
 	LEFT JOIN users u ON p.user_id = u.id
-	WHERE u.username = $1
-	AND p.id = $2`
+	WHERE p.id = $2`
 
-	err := db.QueryRow(query, username, id).
-		Scan(&post.id, &post.content, &post.userId, &post.createdAt, &post.username, &post.likes, &post.replies, &post.liked, &post.parentId)
+	err := db.QueryRowContext(ctx, query, viewer.id, id).
+		Scan(&post.id, &post.content, &post.contentWarning, &post.userId, &post.createdAt, &post.username, &post.likes, &post.replies, &post.liked, &post.parentId)
 
 	if err != nil {
 		if err == sql.ErrNoRows {
 			log.Debug("No post found")
-			return Post{}, false
+			return Post{}, ErrNotFound
 		}
 		log.Errorf("Error while fetching post: %s", err)
-		return Post{}, false
+		return Post{}, fmt.Errorf("Error while fetching post: %w", mapDbError(err))
 	}
 
-	return post, true
+	return post, nil
 }
 
-func FindReplies(db *sql.DB, id int64, viewer SavedUser) ([]Post, error) {
+func FindReplies(ctx context.Context, db *sql.DB, id int64, viewer SavedUser) ([]Post, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
 	query := `
-	SELECT p.id, p.content, p.user_id, p.created_at, u.username, p.likes, p.replies,
+	SELECT p.id, p.content, p.content_warning, p.user_id, p.created_at, u.username, p.likes, p.replies,
 	       CASE WHEN l.user_id IS NOT NULL THEN TRUE ELSE FALSE END AS liked
 	FROM posts p
 	LEFT JOIN likes l ON p.id = l.post_id AND l.user_id = $1
@@ -249,60 +378,71 @@ func FindReplies(db *sql.DB, id int64, viewer SavedUser) ([]Post, error) {
 	ON p.user_id = u.id
 	WHERE p.parent_id = $2
 	ORDER BY p.created_at DESC`
-	rows, err := db.Query(query, viewer.id, id)
+	rows, err := db.QueryContext(ctx, query, viewer.id, id)
 	if err != nil {
-		return nil, err
+		return nil, mapDbError(err)
 	}
 	defer rows.Close()
 
 	var posts []Post
 	for rows.Next() {
 		var post Post
-		if err := rows.Scan(&post.id, &post.content, &post.userId, &post.createdAt, &post.username, &post.likes, &post.replies, &post.liked); err != nil {
-			return nil, err
+		if err := rows.Scan(&post.id, &post.content, &post.contentWarning, &post.userId, &post.createdAt, &post.username, &post.likes, &post.replies, &post.liked); err != nil {
+			return nil, mapDbError(err)
 		}
 		posts = append(posts, post)
 	}
 
 	if err := rows.Err(); err != nil {
-		return nil, err
+		return nil, mapDbError(err)
 	}
 
 	return posts, nil
 }
 
-func ReplyToPost(db *sql.DB, user SavedUser, post Post, content string) (int64, error) {
+func ReplyToPost(ctx context.Context, db *sql.DB, user SavedUser, post Post, content string, contentWarning string) (int64, error) {
+	if err := checkWritable(); err != nil {
+		return 0, err
+	}
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
 	log.Info("Saving reply to db")
 	var id int64
-	query := `SELECT add_reply($1, $2, $3)`
-	err := db.QueryRow(query, user.id, post.id, content).
+	query := `SELECT add_reply($1, $2, $3, NULLIF($4, ''))`
+	err := db.QueryRowContext(ctx, query, user.id, post.id, Sanitize(content), Sanitize(contentWarning)).
 		Scan(&id)
 
 	if err != nil {
 		log.Errorf("failed to insert post: %v", err)
-		return 0, fmt.Errorf("failed to insert post: %v", err)
+		return 0, fmt.Errorf("failed to insert post: %w", mapDbError(err))
 	}
 
 	log.Info("Saved new reply")
+	postsCreated.Inc()
 	return id, nil
 }
 
 func CreateReplyFunction(db *sql.DB) {
+	_, err := db.Exec(`DROP FUNCTION IF EXISTS add_reply(INTEGER, INTEGER, TEXT)`)
+	if err != nil {
+		log.Fatalf("Failed to drop old procedure for inserting replies: %v", err)
+	}
+
 	query := `
-	CREATE OR REPLACE FUNCTION add_reply(user_id_param INTEGER, post_id_param INTEGER, content_param TEXT) RETURNS INTEGER
+	CREATE OR REPLACE FUNCTION add_reply(user_id_param INTEGER, post_id_param INTEGER, content_param TEXT, content_warning_param TEXT) RETURNS INTEGER
 	LANGUAGE plpgsql
 	AS $$
 	DECLARE 
 	        new_id INTEGER;
 	BEGIN
-		INSERT INTO posts (content, user_id, parent_id) 
-		VALUES (content_param, user_id_param, post_id_param) RETURNING id INTO new_id;
+		INSERT INTO posts (content, user_id, parent_id, content_warning) 
+		VALUES (content_param, user_id_param, post_id_param, content_warning_param) RETURNING id INTO new_id;
 		UPDATE posts SET replies = replies + 1 WHERE id = post_id_param;
 	        RETURN new_id;
 	END;
 	$$;`
 
-	_, err := db.Query(query)
+	_, err = db.Query(query)
 	if err != nil {
 		log.Fatalf("Failed to create procedure for inserting replies: %v", err)
 	}
@@ -310,9 +450,11 @@ func CreateReplyFunction(db *sql.DB) {
 	log.Info("Procedure created successfully!")
 }
 
-func FindAllRepliesToUserPosts(db *sql.DB, viewer SavedUser) ([]Post, error) {
+func FindAllRepliesToUserPosts(ctx context.Context, db *sql.DB, viewer SavedUser) ([]Post, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
 	query := `
-	SELECT p.id, p.content, p.user_id, p.created_at, u.username, p.likes, p.replies,
+	SELECT p.id, p.content, p.content_warning, p.user_id, p.created_at, u.username, p.likes, p.replies,
 	       CASE WHEN l.user_id IS NOT NULL THEN TRUE ELSE FALSE END AS liked
 	FROM posts p
 	LEFT JOIN likes l ON p.id = l.post_id AND l.user_id = $1
@@ -320,24 +462,39 @@ func FindAllRepliesToUserPosts(db *sql.DB, viewer SavedUser) ([]Post, error) {
 	RIGHT JOIN posts pa ON p.parent_id = pa.id
 	WHERE p.user_id = $1
 	ORDER BY p.created_at DESC`
-	rows, err := db.Query(query, viewer.id)
+	rows, err := db.QueryContext(ctx, query, viewer.id)
 	if err != nil {
-		return nil, err
+		return nil, mapDbError(err)
 	}
 	defer rows.Close()
 
 	var posts []Post
 	for rows.Next() {
 		var post Post
-		if err := rows.Scan(&post.id, &post.content, &post.userId, &post.createdAt, &post.username, &post.likes, &post.replies, &post.liked); err != nil {
-			return nil, err
+		if err := rows.Scan(&post.id, &post.content, &post.contentWarning, &post.userId, &post.createdAt, &post.username, &post.likes, &post.replies, &post.liked); err != nil {
+			return nil, mapDbError(err)
 		}
 		posts = append(posts, post)
 	}
 
 	if err := rows.Err(); err != nil {
-		return nil, err
+		return nil, mapDbError(err)
 	}
 
 	return posts, nil
 }
+
+// FindHashtagPostsPage reads one page of the posts mentioning
+// #tag. The tag must already be validated with ValidHashtag.
+func FindHashtagPostsPage(ctx context.Context, db *sql.DB, tag string, viewer SavedUser, limit int, offset int) ([]Post, error) {
+	query := `
+	SELECT p.id, p.content, p.content_warning, p.user_id, p.created_at, u.username, p.likes, p.replies,
+	       CASE WHEN l.user_id IS NOT NULL THEN TRUE ELSE FALSE END AS liked
+	FROM posts p
+	LEFT JOIN likes l ON p.id = l.post_id AND l.user_id = $1
+	LEFT JOIN users u ON p.user_id = u.id
+	WHERE p.content ~* ('(^|[^[:alnum:]_])#' || $4 || '([^[:alnum:]_]|$)')
+	ORDER BY p.created_at DESC, p.id DESC
+	LIMIT $2 OFFSET $3`
+	return findPostsPage(ctx, db, query, viewer.id, limit, offset, tag)
+}
@@ -1,18 +1,21 @@
 package main
 
 import (
+	"context"
 	"database/sql"
+	"errors"
 	"fmt"
 	"strings"
 
 	"github.com/charmbracelet/bubbles/textarea"
+	"github.com/charmbracelet/bubbles/textinput"
 	"github.com/charmbracelet/bubbles/viewport"
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/charmbracelet/lipgloss"
 	"github.com/charmbracelet/log"
 )
 
-func getPostView(renderer *lipgloss.Renderer, db *sql.DB, postId int64, user SavedUser) (Tab) {
+func getPostView(parent context.Context, renderer *lipgloss.Renderer, db *sql.DB, postId int64, user SavedUser) (Tab) {
 	infoWidth := 20
 	infoStyle := renderer.NewStyle().
 		MaxWidth(infoWidth).
@@ -33,28 +36,6 @@ func getPostView(renderer *lipgloss.Renderer, db *sql.DB, postId int64, user Sav
 	numberStyle := quitStyle.
 		Bold(true)
 
-	post, postFound :=  GetPostById(db, postId, user.username)
-
-	if (!postFound) {
-		log.Infof("No post with id %d", postId)
-		// TODO: 404 page
-	}
-
-	var parent Post
-	hasParent := false
-
-	if (post.parentId.Valid) {
-		parentId := post.parentId.Int64
-		parent, hasParent =  GetPostById(db, parentId, user.username)
-		if (!hasParent) {
-			log.Infof("No post with id %d", parentId)
-		}
-	}
-
-	isOwner := user.id == post.userId
-
-	tabName := fmt.Sprintf("%s %d", post.username, post.id)
-
 	textInput := textarea.New()
 	textInput.Placeholder = "Type a message..."
 
@@ -64,22 +45,26 @@ func getPostView(renderer *lipgloss.Renderer, db *sql.DB, postId int64, user Sav
 	textInput.FocusedStyle.CursorLine = lipgloss.NewStyle()
 	textInput.ShowLineNumbers = false
 
-	posts, err := FindReplies(db, post.id, user)
-	if err != nil {
-		log.Error(err)
-	}
-	timeline := getTimeline(renderer, db, posts, user)
+	warningInput := textinput.New()
+	warningInput.Placeholder = "Content warning"
+	warningInput.Prompt = ""
+	warningInput.CharLimit = 100
+	warningInput.Width = 28
+
+	timeline := getTimeline(renderer, db, nil, user)
 	vp := viewport.New(20, 15)
-	timeline.PushFront(post)
-	if (hasParent) {
-		timeline.PushFront(parent)
-		timeline.Highlight(1)
-	} else {
-		timeline.Highlight(0)
-	}
+
+	ctx, cancel := context.WithCancel(parent)
+
+	loader := CreateLoader(renderer)
+	loader.Start("Loading post...")
 
 	return Tab{
 		Model: PostViewModel{ 
+			id: nextTabId(),
+			ctx: ctx,
+			cancel: cancel,
+			postId: postId,
 			infoStyle: infoStyle, 
 			quitStyle: quitStyle,
 			postStyle: postStyle,
@@ -89,21 +74,25 @@ func getPostView(renderer *lipgloss.Renderer, db *sql.DB, postId int64, user Sav
 			db: db,
 			renderer: renderer,
 			user: user,
-			post: post,
-			isOwner: isOwner,
 			textarea: textInput,
+			warning: warningInput,
 			inputOpened: false,
-			parent: parent,
-			hasParent: hasParent,
 			posts: timeline,
 			viewport: vp,
+			banner: CreateErrorBanner(renderer),
+			loader: loader,
 		},
-		Name: tabName,
+		Name: fmt.Sprintf("#%d", postId),
 	}
 }
 
 
 type PostViewModel struct {
+	id           int64
+	ctx          context.Context
+	cancel       context.CancelFunc
+	postId       int64
+	loaded       bool
 	infoStyle    lipgloss.Style
 	quitStyle    lipgloss.Style
 	postStyle    lipgloss.Style
@@ -114,18 +103,136 @@ type PostViewModel struct {
 	db           *sql.DB
 	renderer     *lipgloss.Renderer
 	width        int
+	height       int
 	infoWidth    int
 	isOwner      bool
 	textarea     textarea.Model
+	warning      textinput.Model
+	hasWarning   bool
 	inputOpened  bool
+	publishing   bool
 	hasParent    bool
 	parent       Post
 	posts        TimelineModel
 	viewport     viewport.Model
+	banner       ErrorBanner
+	loader       Loader
+}
+
+type PostLoadedMsg struct {
+	tab         int64
+	post        Post
+	parent      Post
+	hasParent   bool
+	replies     []Post
+	err         error
+	parentErr   error
+	repliesErr  error
+}
+
+func loadPost(ctx context.Context, tab int64, db *sql.DB, postId int64, viewer SavedUser) tea.Cmd {
+	return func() tea.Msg {
+		if ctx.Err() != nil {
+			return nil
+		}
+		post, err := GetPostById(ctx, db, postId, viewer)
+		if err != nil {
+			return PostLoadedMsg{tab: tab, err: err}
+		}
+		msg := PostLoadedMsg{tab: tab, post: post}
+		if post.parentId.Valid {
+			parentId := post.parentId.Int64
+			parent, err := GetPostById(ctx, db, parentId, viewer)
+			msg.hasParent = err == nil
+			if errors.Is(err, ErrNotFound) {
+				log.Infof("No post with id %d", parentId)
+			} else if err != nil {
+				msg.parentErr = err
+			}
+			msg.parent = parent
+		}
+		msg.replies, msg.repliesErr = FindReplies(ctx, db, post.id, viewer)
+		posts := append([]Post{msg.parent, msg.post}, msg.replies...)
+		if err := AttachPolls(ctx, db, posts, viewer); err != nil {
+			log.Error(err)
+		}
+		msg.parent, msg.post, msg.replies = posts[0], posts[1], posts[2:]
+		return msg
+	}
+}
+
+type ReplyMsg struct {
+	tab  int64
+	err  error
+}
+
+func replyToPost(ctx context.Context, tab int64, db *sql.DB, user SavedUser, post Post, content string, contentWarning string) tea.Cmd {
+	return func() tea.Msg {
+		if ctx.Err() != nil {
+			return nil
+		}
+		if err := replyLimiter.check(user.username); err != nil {
+			return ReplyMsg{tab: tab, err: err}
+		}
+		id, err := ReplyToPost(ctx, db, user, post, content, contentWarning)
+		if err == nil {
+			PostCreated(ctx, id)
+			NotifyReplyCreated(ctx, id)
+		}
+		return ReplyMsg{tab: tab, err: err}
+	}
 }
 
 func (m PostViewModel) Init() tea.Cmd {
-	return nil
+	return tea.Batch(m.loader.Tick(), loadPost(m.ctx, m.id, m.db, m.postId, m.user))
+}
+
+func (m PostViewModel) Draft() (Draft, bool) {
+	if !m.inputOpened || m.textarea.Value() == "" {
+		return Draft{}, false
+	}
+	return Draft{content: m.textarea.Value(), contentWarning: m.contentWarning(), parentId: sql.NullInt64{Int64: m.postId, Valid: true}}, true
+}
+
+func (m PostViewModel) contentWarning() string {
+	if !m.hasWarning {
+		return ""
+	}
+	return strings.TrimSpace(m.warning.Value())
+}
+
+// closeReply hides the reply input and drops what was typed.
+func (m *PostViewModel) closeReply() {
+	m.textarea.Blur()
+	m.textarea.Reset()
+	m.warning.Blur()
+	m.warning.Reset()
+	m.hasWarning = false
+	m.inputOpened = false
+}
+
+// OpenDraft opens the reply input with a draft saved when the
+// server restarted.
+func (m PostViewModel) OpenDraft(draft Draft) (PostViewModel, tea.Cmd) {
+	m.textarea.SetValue(draft.content)
+	if draft.contentWarning != "" {
+		m.hasWarning = true
+		m.warning.SetValue(draft.contentWarning)
+	}
+	m.inputOpened = true
+	m.layout()
+	return m, m.textarea.Focus()
+}
+
+func (m PostViewModel) Close() {
+	m.cancel()
+}
+
+func (m PostViewModel) Title() string {
+	if !m.loaded {
+		return fmt.Sprintf("#%d", m.postId)
+	}
+	return fmt.Sprintf("%s %d", m.post.username, m.post.id)
 }
 
 
@@ -133,56 +240,198 @@ func (m PostViewModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	switch msg := msg.(type) {
 	case tea.WindowSizeMsg: 
 		m.width = msg.Width
+		m.height = msg.Height
 		m.posts.width = max(m.width - (m.infoWidth + 1), 20) - 2
 		m.viewport.Width = m.posts.width
-		m.viewport.Height = msg.Height - 5
+		m.layout()
+		m.viewport.SetContent(m.posts.View())
+		return m, nil
+	case PostLoadedMsg:
+		if msg.tab != m.id {
+			return m, nil
+		}
+		m.loader.Done()
+		name := fmt.Sprintf("#%d", m.postId)
+		if errors.Is(msg.err, ErrNotFound) {
+			log.Infof("No post with id %d", m.postId)
+			m.cancel()
+			return getNotFoundView(m.renderer, name, "This post doesn't exist or was deleted.").Model, nil
+		}
+		if msg.err != nil {
+			log.Error("Could not load post", "error", msg.err)
+			m.cancel()
+			return getErrorView(m.renderer, name, "Could not load this post.").Model, nil
+		}
+		m.loaded = true
+		m.post = msg.post
+		m.parent = msg.parent
+		m.hasParent = msg.hasParent
+		m.isOwner = m.user.id == m.post.userId
+		if msg.parentErr != nil {
+			m.banner.Show("Could not load the parent post", msg.parentErr)
+		}
+		if msg.repliesErr != nil {
+			m.banner.Show("Could not load replies", msg.repliesErr)
+		}
+		m.posts = getTimeline(m.renderer, m.db, msg.replies, m.user)
+		m.posts.width = max(m.width - (m.infoWidth + 1), 20) - 2
+		m.posts.PushFront(m.post)
+		if (m.hasParent) {
+			m.posts.PushFront(m.parent)
+			m.posts.Highlight(1)
+		} else {
+			m.posts.Highlight(0)
+		}
+		m.layout()
 		m.viewport.SetContent(m.posts.View())
 		return m, nil
+	case ReportMsg:
+		if msg.tab != m.id {
+			return m, nil
+		}
+		m.loader.Done()
+		if msg.err != nil {
+			m.banner.Show("Could not report the post", msg.err)
+		} else {
+			m.banner.Info("Reported, thank you. Moderators will take a look.")
+		}
+		m.layout()
+		return m, nil
+	case ReplyMsg:
+		if msg.tab != m.id {
+			return m, nil
+		}
+		m.loader.Done()
+		m.publishing = false
+		if msg.err != nil {
+			// The input keeps the reply, so it can be sent again.
+			m.banner.Show("Could not publish your reply", msg.err)
+			m.layout()
+			return m, m.textarea.Focus()
+		}
+		m.closeReply()
+		m.layout()
+		return m, nil
 	case tea.KeyMsg:
-		if m.textarea.Focused() {
+		if !m.loaded {
+			return m, nil
+		}
+		if m.publishing {
+			return m, nil
+		}
+		if m.inputOpened {
 			switch msg.String() {
 			case "esc":
-				m.textarea.Blur()
-				m.inputOpened = false
-				m.viewport.Height = m.viewport.Height + 4
+				m.closeReply()
+				m.layout()
 				return m, nil
 			case "enter":
-				m.textarea.Blur()
-				m.inputOpened = false
 				text := m.textarea.Value()
-				m.viewport.Height = m.viewport.Height + 4
-				m.textarea.Reset()
+				warning := m.contentWarning()
 				if (text == "") { 
+					m.closeReply()
+					m.layout()
 					return m, nil
 				}
-				ReplyToPost(m.db, m.user, m.post, text)
+				m.publishing = true
+				m.textarea.Blur()
+				m.warning.Blur()
+				cmd := tea.Batch(
+					m.loader.Start("Publishing..."),
+					replyToPost(m.ctx, m.id, m.db, m.user, m.post, text, warning),
+				)
+				m.layout()
+				return m, cmd
+			case "ctrl+g":
+				m.hasWarning = !m.hasWarning
+				if !m.hasWarning {
+					m.warning.Reset()
+					m.warning.Blur()
+					m.layout()
+					return m, m.textarea.Focus()
+				}
+				m.layout()
 				return m, nil
+			case "tab":
+				if m.hasWarning && m.textarea.Focused() {
+					m.textarea.Blur()
+					return m, m.warning.Focus()
+				}
+				m.warning.Blur()
+				return m, m.textarea.Focus()
 			default:
 				var cmd tea.Cmd
-				m.textarea, cmd = m.textarea.Update(msg)
+				if m.warning.Focused() {
+					m.warning, cmd = m.warning.Update(msg)
+				} else {
+					m.textarea, cmd = m.textarea.Update(msg)
+				}
 				return m, cmd
 			}
 		} else {
+			switch msg.String() {
+			case "p", "!":
+				if refuseWrite(&m.banner) {
+					m.layout()
+					return m, nil
+				}
+			}
 			switch msg.String() {
 			case "p":
 				m.inputOpened = true
-				m.viewport.Height = m.viewport.Height - 4
+				m.layout()
 				return m, m.textarea.Focus()
+			case "e":
+				m.posts.ToggleWarning(m.posts.highlighted)
+				m.viewport.SetContent(m.posts.View())
+				return m, nil
+			case "!":
+				cmd := tea.Batch(m.loader.Start("Reporting..."), reportPost(m.ctx, m.id, m.db, m.user, m.post))
+				m.layout()
+				return m, cmd
 			}
 		}
-
+		return m, nil
 	}
-	return m, nil
+	var cmd tea.Cmd
+	m.loader, cmd = m.loader.Update(msg)
+	return m, cmd
 }
 
 func (m PostViewModel) View() string {
 	doc := strings.Builder{}
+	if m.loader.Height() > 0 {
+		doc.WriteString(m.loader.View())
+		doc.WriteString("\n")
+	}
+	if m.banner.Height() > 0 {
+		doc.WriteString(m.banner.View())
+		doc.WriteString("\n")
+	}
 	if m.inputOpened {
+		if m.hasWarning {
+			doc.WriteString(m.quitStyle.Render("CW: "))
+			doc.WriteString(m.warning.View())
+			doc.WriteString("\n")
+		}
 		doc.WriteString(m.textarea.View())
 		doc.WriteString("\n")
+		doc.WriteString(m.quitStyle.Render("ctrl+g: content warning"))
+		doc.WriteString("\n")
 	}
 
 	doc.WriteString(m.viewport.View())
 
 	return doc.String()
 }
+
+func (m *PostViewModel) layout() {
+	height := m.height - 5 - m.loader.Height() - m.banner.Height()
+	if m.inputOpened {
+		height -= 5
+		if m.hasWarning {
+			height -= 1
+		}
+	}
+	m.viewport.Height = max(height, 0)
+}
@@ -1,19 +1,19 @@
 package main
 
 import (
+	"context"
 	"database/sql"
+	"errors"
 	"strconv"
 	"strings"
-	"time"
 
-	"github.com/charmbracelet/bubbles/textarea"
 	"github.com/charmbracelet/bubbles/viewport"
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/charmbracelet/lipgloss"
 	"github.com/charmbracelet/log"
 )
 
-func getProfileView(renderer *lipgloss.Renderer, db *sql.DB, username string, user SavedUser) (Tab) {
+func getProfileView(parent context.Context, renderer *lipgloss.Renderer, db *sql.DB, username string, user SavedUser) (Tab) {
 	infoWidth := 20
 	infoStyle := renderer.NewStyle().
 		MaxWidth(infoWidth).
@@ -34,44 +34,26 @@ func getProfileView(renderer *lipgloss.Renderer, db *sql.DB, username string, us
 	numberStyle := quitStyle.
 		Bold(true)
 
-	owner, found :=  GetUserByUsername(db, username)
-	if (!found) {
-		log.Info("No such user")
-		// TODO: 404 page
-	}
-	isOwner := user.id == owner.id
-	follows := false;
-	if !isOwner {
-		var err error;
-		follows, err = CheckFollow(db, user, owner);
-		if err != nil {
-			log.Error("Error while checking following.")
-		}
-	} 
-
+	isOwner := user.username == username
+	owner := SavedUser{username: username}
+	timeline := getTimeline(renderer, db, nil, user)
 
-	posts, err := FindUserPosts(db, owner, user)
-	if err != nil {
-		log.Error(err)
-	}
-	timeline := getTimeline(renderer, db, posts, user)
-
-	info := getProfileInfo(renderer, db, owner, follows)
+	info := getProfileInfo(renderer, db, owner, false)
 
-	textInput := textarea.New()
-	textInput.Placeholder = "Type a message..."
+	composer := CreateComposer(renderer)
 
-	textInput.CharLimit = 280
-	textInput.SetWidth(30)
-	textInput.SetHeight(3)
-	textInput.FocusedStyle.CursorLine = lipgloss.NewStyle()
-	textInput.ShowLineNumbers = false
+	newViewport := viewport.New(20, 15)
 
+	ctx, cancel := context.WithCancel(parent)
 
-	newViewport := viewport.New(20, 15)
+	loader := CreateLoader(renderer)
+	loader.Start("Loading profile...")
 
 	return Tab{
 		Model: ProfileViewModel{ 
+			id: nextTabId(),
+			ctx: ctx,
+			cancel: cancel,
 			infoStyle: infoStyle, 
 			quitStyle: quitStyle,
 			postStyle: postStyle,
@@ -85,9 +67,11 @@ func getProfileView(renderer *lipgloss.Renderer, db *sql.DB, username string, us
 			posts: timeline,
 			user: user,
 			isOwner: isOwner,
-			text: textInput,
+			composer: composer,
 			inputOpened: false,
 			viewport: newViewport,
+			banner: CreateErrorBanner(renderer),
+			loader: loader,
 		},
 		Name: username,
 	}
@@ -95,6 +79,9 @@ func getProfileView(renderer *lipgloss.Renderer, db *sql.DB, username string, us
 
 
 type ProfileViewModel struct {
+	id           int64
+	ctx          context.Context
+	cancel       context.CancelFunc
 	infoStyle    lipgloss.Style
 	quitStyle    lipgloss.Style
 	postStyle    lipgloss.Style
@@ -107,102 +94,335 @@ type ProfileViewModel struct {
 	db           *sql.DB
 	renderer     *lipgloss.Renderer
 	width        int
+	height       int
 	infoWidth    int
 	isOwner      bool
-	text         textarea.Model
+	loaded       bool
+	confirmDelete bool
+	composer     Composer
 	inputOpened  bool
+	publishing   bool
 	viewport     viewport.Model
+	banner       ErrorBanner
+	loader       Loader
+}
+
+type ProfileLoadedMsg struct {
+	tab        int64
+	owner      SavedUser
+	follows    bool
+	posts      []Post
+	err        error
+	followErr  error
+	postsErr   error
+}
+
+func loadProfile(ctx context.Context, tab int64, db *sql.DB, username string, viewer SavedUser) tea.Cmd {
+	return func() tea.Msg {
+		if ctx.Err() != nil {
+			return nil
+		}
+		owner, err := GetUserByUsername(ctx, db, username)
+		if err != nil {
+			return ProfileLoadedMsg{tab: tab, err: err}
+		}
+		msg := ProfileLoadedMsg{tab: tab, owner: owner}
+		if viewer.id != owner.id {
+			msg.follows, msg.followErr = CheckFollow(ctx, db, viewer, owner)
+		}
+		msg.posts, msg.postsErr = FindUserPosts(ctx, db, owner, viewer)
+		if msg.postsErr == nil {
+			if err := AttachPolls(ctx, db, msg.posts, viewer); err != nil {
+				log.Error(err)
+			}
+		}
+		return msg
+	}
+}
+
+type FollowMsg struct {
+	tab      int64
+	follows  bool
+	err      error
+}
+
+func toggleFollow(ctx context.Context, tab int64, db *sql.DB, user SavedUser, owner SavedUser, follows bool) tea.Cmd {
+	return func() tea.Msg {
+		if ctx.Err() != nil {
+			return nil
+		}
+		if err := followLimiter.check(user.username); err != nil {
+			return FollowMsg{tab: tab, follows: !follows, err: err}
+		}
+		var err error
+		if follows {
+			err = DeleteFollow(ctx, db, user, owner)
+			if err == nil {
+				FollowDeleted(ctx, user, owner)
+			}
+		} else {
+			err = SaveFollow(ctx, db, user, owner)
+			if err == nil {
+				FollowCreated(ctx, user, owner)
+				NotifyFollowCreated(ctx, user, owner)
+			}
+		}
+		return FollowMsg{tab: tab, follows: !follows, err: err}
+	}
 }
 
 func (m ProfileViewModel) Init() tea.Cmd {
-	return nil
+	return tea.Batch(
+		m.loader.Tick(),
+		loadProfile(m.ctx, m.id, m.db, m.owner.username, m.user),
+	)
 }
 
+func (m ProfileViewModel) Close() {
+	m.cancel()
+}
 
 func (m ProfileViewModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	switch msg := msg.(type) {
 	case tea.WindowSizeMsg: 
 		m.width = msg.Width
+		m.height = msg.Height
 		m.posts.width = max(m.width - (m.infoWidth + 1), 20) - 2
 		m.viewport.Width = m.posts.width
-		m.viewport.Height = msg.Height - 5
+		m.layout()
+		m.viewport.SetContent(m.posts.View())
+		return m, nil
+	case ProfileLoadedMsg:
+		if msg.tab != m.id {
+			return m, nil
+		}
+		m.loader.Done()
+		if errors.Is(msg.err, ErrNotFound) {
+			log.Info("No such user")
+			m.cancel()
+			return getNotFoundView(m.renderer, m.owner.username, "There is no user named " + SanitizeLine(m.owner.username) + ".").Model, nil
+		}
+		if msg.err != nil {
+			log.Error("Could not load profile", "error", msg.err)
+			m.cancel()
+			return getErrorView(m.renderer, m.owner.username, "Could not load this profile.").Model, nil
+		}
+		m.loaded = true
+		m.owner = msg.owner
+		m.isOwner = m.user.id == m.owner.id
+		m.banner.Clear()
+		if msg.followErr != nil {
+			m.banner.Show("Could not check if you follow this user", msg.followErr)
+		}
+		if msg.postsErr != nil {
+			m.banner.Show("Could not load posts", msg.postsErr)
+		}
+		m.info = getProfileInfo(m.renderer, m.db, m.owner, msg.follows)
+		m.posts = getTimeline(m.renderer, m.db, msg.posts, m.user)
+		m.posts.width = max(m.width - (m.infoWidth + 1), 20) - 2
+		m.layout()
+		m.viewport.SetContent(m.posts.View())
+		return m, nil
+	case PostsLoadedMsg:
+		if msg.tab != m.id {
+			return m, nil
+		}
+		m.loader.Done()
+		if msg.err != nil {
+			m.banner.Show("Could not load posts", msg.err)
+		} else {
+			m.banner.Clear()
+			m.posts = getTimeline(m.renderer, m.db, msg.posts, m.user)
+			m.posts.width = max(m.width - (m.infoWidth + 1), 20) - 2
+		}
+		m.layout()
 		m.viewport.SetContent(m.posts.View())
 		return m, nil
+	case ReportMsg:
+		if msg.tab != m.id {
+			return m, nil
+		}
+		m.loader.Done()
+		if msg.err != nil {
+			m.banner.Show("Could not report the post", msg.err)
+		} else {
+			m.banner.Info("Reported, thank you. Moderators will take a look.")
+		}
+		m.layout()
+		return m, nil
+	case PostSavedMsg:
+		if msg.tab != m.id {
+			return m, nil
+		}
+		m.loader.Done()
+		m.publishing = false
+		if msg.err != nil {
+			// The composer keeps the post, so it can be sent again.
+			m.banner.Show("Could not publish your post", msg.err)
+			m.layout()
+			return m, m.composer.Focus()
+		}
+		m.closeComposer()
+		m.posts.Push(msg.post)
+		m.viewport.SetContent(m.posts.View())
+		m.viewport.GotoTop()
+		m.layout()
+		return m, nil
+	case FollowMsg:
+		if msg.tab != m.id {
+			return m, nil
+		}
+		m.loader.Done()
+		if errors.Is(msg.err, ErrAlreadyFollowing) || errors.Is(msg.err, ErrNotFollowing) {
+			m.banner.Show("Could not update the follow", msg.err)
+			m.info.isFollowed = msg.follows
+		} else if msg.err != nil && msg.follows {
+			m.banner.Show("Could not follow this user", msg.err)
+		} else if msg.err != nil {
+			m.banner.Show("Could not unfollow this user", msg.err)
+		} else {
+			m.info.isFollowed = msg.follows
+			if msg.follows {
+				m.owner.followers += 1
+			} else {
+				m.owner.followers -= 1
+			}
+			m.info.user.followers = m.owner.followers
+		}
+		m.layout()
+		return m, nil
+	case LikeMsg:
+		if msg.tab != m.id {
+			return m, nil
+		}
+		m.loader.Done()
+		if errors.Is(msg.err, ErrAlreadyLiked) || errors.Is(msg.err, ErrNotLiked) {
+			// the like was changed in another session
+			m.banner.Show("Could not update the like", msg.err)
+			m.posts.ApplyLike(msg.postId, msg.liked)
+			m.viewport.SetContent(m.posts.View())
+		} else if msg.err != nil {
+			m.banner.Show("Could not update the like", msg.err)
+		} else {
+			m.posts.ApplyLike(msg.postId, msg.liked)
+			m.viewport.SetContent(m.posts.View())
+		}
+		m.layout()
+		return m, nil
+	case VoteMsg:
+		if msg.tab != m.id {
+			return m, nil
+		}
+		m.loader.Done()
+		if msg.err != nil {
+			m.banner.Show("Could not save your vote", msg.err)
+		} else {
+			m.posts.ApplyVote(msg.postId, msg.option)
+			m.viewport.SetContent(m.posts.View())
+		}
+		m.layout()
+		return m, nil
+	case AccountDeletedMsg:
+		if msg.tab != m.id {
+			return m, nil
+		}
+		m.loader.Done()
+		if msg.err != nil {
+			m.banner.Show("Could not delete this account", msg.err)
+			m.layout()
+			return m, nil
+		}
+		m.cancel()
+		return getNotFoundView(m.renderer, m.owner.username, "This account was deleted.").Model, nil
 	case tea.KeyMsg:
-		if m.text.Focused() {
+		if !m.loaded {
+			return m, nil
+		}
+		if m.confirmDelete {
+			m.confirmDelete = false
+			var cmd tea.Cmd
+			if msg.String() == "y" {
+				cmd = tea.Batch(
+					m.loader.Start("Deleting account..."),
+					deleteAccount(m.ctx, m.id, m.db, m.owner),
+				)
+			}
+			m.layout()
+			return m, cmd
+		}
+		if m.publishing {
+			return m, nil
+		}
+		if m.inputOpened {
 			switch msg.String() {
 			case "esc":
-				m.text.Blur()
-				m.inputOpened = false
-				m.viewport.Height = m.viewport.Height + 4
+				m.closeComposer()
 				return m, nil
 			case "enter":
-				m.text.Blur()
-				m.inputOpened = false
-				m.viewport.Height = m.viewport.Height + 4
-				text := m.text.Value()
-				m.text.Reset()
-				if (text == "") { // TODO
+				valid := m.composer.Validate()
+				m.layout()
+				if !valid {
 					return m, nil
 				}
-				id, err := SavePost(m.db, m.user, text) // TODO: extract to commad
-				if err == nil {
-					m.posts.Push(Post {
-						id: id, 
-						userId: m.user.id, 
-						content: text, 
-						username: m.user.username, 
-						createdAt: time.Now(),
-					})
-					m.viewport.SetContent(m.posts.View())
-					m.viewport.GotoTop()
-				} else {
-					log.Error(err)
+				text := m.composer.Value()
+				if (text == "") { // TODO
+					m.closeComposer()
+					return m, nil
 				}
-
-				return m, nil
+				cmd := savePost(m.ctx, m.id, m.db, m.user, m.composer)
+				m.publishing = true
+				m.composer.Blur()
+				cmd = tea.Batch(m.loader.Start("Publishing..."), cmd)
+				m.layout()
+				return m, cmd
 			default:
 				var cmd tea.Cmd
-				m.text, cmd = m.text.Update(msg)
+				m.composer, cmd = m.composer.Update(msg)
+				m.layout()
 				return m, cmd
 			}
 		} else {
+			switch msg.String() {
+			case "p", "D", "f", "1", "2", "3", "4", "l", "!":
+				if refuseWrite(&m.banner) {
+					m.layout()
+					return m, nil
+				}
+			}
 			switch msg.String() {
 			case "p":
 				if m.isOwner {
 					m.inputOpened = true
-					m.viewport.Height = m.viewport.Height - 4
-					return m, m.text.Focus()
+					m.layout()
+					return m, m.composer.Focus()
 				}
 			case "r":
-				posts, err := FindUserPosts(m.db, m.owner, m.user)
-				if err != nil {
-					log.Error(err)
+				owner := m.owner
+				find := func(ctx context.Context, db *sql.DB, viewer SavedUser) ([]Post, error) {
+					return FindUserPosts(ctx, db, owner, viewer)
+				}
+				cmd := tea.Batch(
+					m.loader.Start("Loading posts..."),
+					loadPosts(m.ctx, m.id, m.db, m.user, find),
+				)
+				m.layout()
+				return m, cmd
+			case "D":
+				if m.user.administrator && !m.isOwner {
+					m.confirmDelete = true
+					m.layout()
 				}
-				m.posts = getTimeline(m.renderer, m.db, posts, m.user)
-				m.viewport.SetContent(m.posts.View())
 				return m, nil
 			case "f":
 				if m.isOwner {
 					return m, nil
 				}
-				if !m.info.isFollowed {
-					err := SaveFollow(m.db, m.user, m.owner)
-					if err == nil {
-						m.info.isFollowed = true
-						m.owner.followers += 1
-						m.info.user.followers += 1
-					}
-					return m, nil
-				} else {
-					err := DeleteFollow(m.db, m.user, m.owner)
-					if err == nil {
-						m.info.isFollowed = false
-						m.owner.followers -= 1
-						m.info.user.followers -= 1
-					}
-					return m, nil
-				}
+				cmd := tea.Batch(
+					m.loader.Start("Saving..."),
+					toggleFollow(m.ctx, m.id, m.db, m.user, m.owner, m.info.isFollowed),
+				)
+				m.layout()
+				return m, cmd
 			case "k", "j":
 				m.posts, m.viewport = UpdateTimeline(m.posts, m.viewport, msg)
 				return m, nil
@@ -210,32 +430,44 @@ func (m ProfileViewModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 				var cmd tea.Cmd
 				m.posts, cmd = m.posts.Update(msg)
 				return m, cmd
+			case "e":
+				m.posts.ToggleWarning(m.posts.currentPost)
+				m.viewport.SetContent(m.posts.View())
+				return m, nil
+			case "1", "2", "3", "4":
+				option := int(msg.String()[0] - '1')
+				post, ok := m.posts.Votable(option)
+				if !ok {
+					return m, nil
+				}
+				cmd := tea.Batch(m.loader.Start("Voting..."), vote(m.ctx, m.id, m.db, m.user, post, option))
+				m.layout()
+				return m, cmd
 			case "l":
-				var err error
-				post := m.posts.posts[m.posts.currentPost]
-				if post.liked {
-					err = DeleteLike(m.db, m.user, post)
-				} else {
-					err = SaveLike(m.db, m.user, post)
+				post, ok := m.posts.Current()
+				if !ok {
+					return m, nil
 				}
-				
-				if err == nil {
-					m.posts.posts[m.posts.currentPost].liked = !post.liked
-					if post.liked {
-						m.posts.posts[m.posts.currentPost].likes = post.likes - 1 
-					} else {
-						m.posts.posts[m.posts.currentPost].likes = post.likes + 1 
-					}
-					m.viewport.SetContent(m.posts.View())
+				cmd := tea.Batch(m.loader.Start("Saving like..."), toggleLike(m.ctx, m.id, m.db, m.user, post))
+				m.layout()
+				return m, cmd
+			case "!":
+				post, ok := m.posts.Current()
+				if !ok {
+					return m, nil
 				}
-				return m, nil
+				cmd := tea.Batch(m.loader.Start("Reporting..."), reportPost(m.ctx, m.id, m.db, m.user, post))
+				m.layout()
+				return m, cmd
 			}
 			var cmd tea.Cmd
 			m.viewport, cmd = m.viewport.Update(msg)
 			return m, cmd
 		}
 	}
-	return m, nil
+	var cmd tea.Cmd
+	m.loader, cmd = m.loader.Update(msg)
+	return m, cmd
 }
 
 func (m ProfileViewModel) View() string {
@@ -244,8 +476,18 @@ func (m ProfileViewModel) View() string {
 		Render(m.info.View())
 
 	posts := make([]string, 0)
+	if m.loader.Height() > 0 {
+		posts = append(posts, m.loader.View())
+	}
+	if m.banner.Height() > 0 {
+		posts = append(posts, m.banner.View())
+	}
+	if m.confirmDelete {
+		prompt := "Delete " + SanitizeLine(m.owner.username) + "'s account? y: delete • any key: cancel"
+		posts = append(posts, m.headerStyle.Render(prompt))
+	}
 	if m.inputOpened {
-		posts = append(posts, m.text.View() + "\n")
+		posts = append(posts, m.composer.View())
 	}
 	posts = append(posts, m.viewport.View())
 	renderedPosts := lipgloss.JoinVertical(lipgloss.Top, posts...)
@@ -261,6 +503,40 @@ func (m ProfileViewModel) View() string {
 	return doc
 }
 
+func (m *ProfileViewModel) layout() {
+	height := m.height - 5 - m.loader.Height() - m.banner.Height()
+	if m.confirmDelete {
+		height -= 1
+	}
+	if m.inputOpened {
+		height -= m.composer.Height()
+	}
+	m.viewport.Height = max(height, 0)
+}
+
+func (m ProfileViewModel) Draft() (Draft, bool) {
+	if !m.inputOpened || m.composer.Value() == "" {
+		return Draft{}, false
+	}
+	return Draft{content: m.composer.Value(), contentWarning: m.composer.ContentWarning()}, true
+}
+
+// OpenDraft opens the composer with a draft saved when the
+// server restarted.
+func (m ProfileViewModel) OpenDraft(draft Draft) (ProfileViewModel, tea.Cmd) {
+	m.composer.Reset()
+	m.composer.SetDraft(draft)
+	m.inputOpened = true
+	m.layout()
+	return m, m.composer.Focus()
+}
+
+func (m *ProfileViewModel) closeComposer() {
+	m.inputOpened = false
+	m.composer.Reset()
+	m.layout()
+}
+
 
 func getProfileInfo(renderer *lipgloss.Renderer, db *sql.DB, user SavedUser, isFollowed bool) (ProfileInfoModel) {
 	txtStyle := renderer.NewStyle().Foreground(lipgloss.Color("10"))
@@ -304,12 +580,15 @@ func (m ProfileInfoModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 
 func (m ProfileInfoModel) View() string {
 	doc := strings.Builder{}
-	username := m.headerStyle.Render(m.user.username)  
+	username := m.headerStyle.Render(SanitizeLine(m.user.username))  
 	doc.WriteString(username)
+	if m.user.id == 0 {
+		return doc.String()
+	}
 	doc.WriteString("\n")
 	desc := "description"
 	if m.user.description.Valid {
-		desc = m.user.description.String
+		desc = SanitizeLine(m.user.description.String)
 	}
 	description := m.txtStyle.Render(desc)  
 	doc.WriteString(description)
@@ -320,7 +599,7 @@ func (m ProfileInfoModel) View() string {
 	doc.WriteString("📍 " )
 	loc := ""
 	if m.user.location.Valid {
-		loc = m.user.location.String
+		loc = SanitizeLine(m.user.location.String)
 	}
 	location := m.quitStyle.Render(loc)  
 	doc.WriteString(location)
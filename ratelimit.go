@@ -0,0 +1,165 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/charmbracelet/log"
+)
+
+// RateLimit allows count actions per period, refilled evenly;
+// a count of 0 disables the limit.
+type RateLimit struct {
+	count  int
+	period time.Duration
+}
+
+func (l RateLimit) String() string {
+	if l.count == 0 {
+		return "off"
+	}
+	return fmt.Sprintf("%d/%s", l.count, l.period)
+}
+
+// ParseRateLimit reads limits like "10/1m" or "off".
+func ParseRateLimit(s string) (RateLimit, error) {
+	s = strings.TrimSpace(s)
+	if s == "off" || s == "0" {
+		return RateLimit{}, nil
+	}
+	count, period, found := strings.Cut(s, "/")
+	if !found {
+		return RateLimit{}, errors.New("use a limit like 10/1m, or off")
+	}
+	n, err := strconv.Atoi(count)
+	if err != nil || n < 0 {
+		return RateLimit{}, fmt.Errorf("invalid count %q", count)
+	}
+	d, err := time.ParseDuration(period)
+	if err != nil || d <= 0 {
+		return RateLimit{}, fmt.Errorf("invalid period %q", period)
+	}
+	return RateLimit{count: n, period: d}, nil
+}
+
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// RateLimiter keeps a token bucket per key, like a username or
+// an IP address.
+type RateLimiter struct {
+	name      string
+	mu        sync.Mutex
+	limit     RateLimit
+	buckets   map[string]*bucket
+	lastPrune time.Time
+}
+
+func NewRateLimiter(name string, limit RateLimit) *RateLimiter {
+	return &RateLimiter{name: name, limit: limit, buckets: map[string]*bucket{}}
+}
+
+func (l *RateLimiter) SetLimit(limit RateLimit) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.limit = limit
+	l.buckets = map[string]*bucket{}
+}
+
+// refill adds the tokens earned since the bucket was last used.
+func (l *RateLimiter) refill(b *bucket, now time.Time) {
+	rate := float64(l.limit.count) / l.limit.period.Seconds()
+	b.tokens = min(float64(l.limit.count), b.tokens + now.Sub(b.last).Seconds() * rate)
+	b.last = now
+}
+
+// Allow takes a token from the bucket of key. Refused actions
+// are logged and counted.
+func (l *RateLimiter) Allow(key string) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.limit.count == 0 {
+		return true
+	}
+	now := time.Now()
+	l.prune(now)
+	b, ok := l.buckets[key]
+	if !ok {
+		b = &bucket{tokens: float64(l.limit.count), last: now}
+		l.buckets[key] = b
+	}
+	l.refill(b, now)
+	if b.tokens < 1 {
+		log.Warn("Rate limited", "action", l.name, "key", key, "limit", l.limit)
+		rateLimited.With("action", l.name).Add(1)
+		return false
+	}
+	b.tokens -= 1
+	return true
+}
+
+// prune forgets full buckets, which behave like new ones.
+func (l *RateLimiter) prune(now time.Time) {
+	if now.Sub(l.lastPrune) < time.Minute {
+		return
+	}
+	l.lastPrune = now
+	for key, b := range l.buckets {
+		l.refill(b, now)
+		if b.tokens >= float64(l.limit.count) {
+			delete(l.buckets, key)
+		}
+	}
+}
+
+// check returns ErrRateLimited when key is out of tokens.
+func (l *RateLimiter) check(key string) error {
+	if !l.Allow(key) {
+		return ErrRateLimited
+	}
+	return nil
+}
+
+var (
+	postLimiter     = NewRateLimiter("posts", RateLimit{10, time.Minute})
+	replyLimiter    = NewRateLimiter("replies", RateLimit{20, time.Minute})
+	likeLimiter     = NewRateLimiter("likes", RateLimit{60, time.Minute})
+	followLimiter   = NewRateLimiter("follows", RateLimit{30, time.Minute})
+	authLimiter     = NewRateLimiter("auth", RateLimit{30, time.Minute})
+	registerLimiter = NewRateLimiter("register", RateLimit{5, time.Hour})
+)
+
+// rateLimiters are configured with RATE_LIMIT_<NAME>, like
+// RATE_LIMIT_POSTS=10/1m.
+var rateLimiters = []*RateLimiter{
+	postLimiter, replyLimiter, likeLimiter, followLimiter, authLimiter, registerLimiter,
+}
+
+func ConfigureRateLimits() error {
+	for _, limiter := range rateLimiters {
+		variable := "RATE_LIMIT_" + strings.ToUpper(limiter.name)
+		limit, err := ParseRateLimit(GetEnvOrDefault(variable, limiter.limit.String()))
+		if err != nil {
+			return fmt.Errorf("%s: %w", variable, err)
+		}
+		limiter.SetLimit(limit)
+	}
+	return nil
+}
+
+// remoteIp drops the port of a client address, so every
+// connection from a host shares a bucket.
+func remoteIp(addr net.Addr) string {
+	host, _, err := net.SplitHostPort(addr.String())
+	if err != nil {
+		return addr.String()
+	}
+	return host
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"sync/atomic"
+
+	"github.com/charmbracelet/log"
+)
+
+// readOnlyMode refuses writes while the database is under
+// maintenance; sessions keep reading.
+var readOnlyMode atomic.Bool
+
+const readOnlyNotice = "Read-only mode: posting, likes and follows are paused for maintenance"
+
+// ReadOnlyMsg is broadcast to every session when read-only
+// mode is toggled.
+type ReadOnlyMsg struct {
+	enabled bool
+}
+
+func ReadOnly() bool {
+	return readOnlyMode.Load()
+}
+
+// SetReadOnly toggles read-only mode and tells every session.
+// by names who toggled it, for the logs.
+func SetReadOnly(enabled bool, by string) {
+	if readOnlyMode.Swap(enabled) == enabled {
+		return
+	}
+	log.Warn("Read-only mode toggled", "enabled", enabled, "by", by)
+	programs.Broadcast(ReadOnlyMsg{enabled: enabled})
+}
+
+// refuseWrite shows the read-only error on banner and tells
+// the view to drop the key that would have written.
+func refuseWrite(banner *ErrorBanner) bool {
+	if !ReadOnly() {
+		return false
+	}
+	banner.Show("Read-only mode", ErrReadOnly)
+	return true
+}
+
+// checkWritable is called by repository functions that write
+// on behalf of users.
+func checkWritable() error {
+	if ReadOnly() {
+		return ErrReadOnly
+	}
+	return nil
+}
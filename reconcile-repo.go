@@ -0,0 +1,189 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/charmbracelet/log"
+)
+
+// CounterDiscrepancy is a denormalized counter that didn't
+// match the rows it counts.
+type CounterDiscrepancy struct {
+	table   string
+	id      int64
+	column  string
+	stored  int
+	actual  int
+}
+
+func (d CounterDiscrepancy) String() string {
+	return fmt.Sprintf("%s %d: %s was %d, should be %d", d.table, d.id, d.column, d.stored, d.actual)
+}
+
+type ReconcileReport struct {
+	posts          int
+	users          int
+	discrepancies  []CounterDiscrepancy
+}
+
+// Reconcile recomputes likes, replies, followers and followed
+// from the likes, posts and follows tables. Every batch of ids
+// is fixed in its own transaction, so a long run doesn't hold
+// locks on the whole table. With dryRun nothing is written.
+func Reconcile(ctx context.Context, db *sql.DB, batch int, dryRun bool) (ReconcileReport, error) {
+	report := ReconcileReport{}
+	var err error
+	report.posts, err = reconcileBatches(ctx, db, "posts", batch, func(from int64, to int64) ([]CounterDiscrepancy, int, error) {
+		return reconcilePosts(ctx, db, from, to, dryRun)
+	}, &report)
+	if err != nil {
+		return report, err
+	}
+	report.users, err = reconcileBatches(ctx, db, "users", batch, func(from int64, to int64) ([]CounterDiscrepancy, int, error) {
+		return reconcileUsers(ctx, db, from, to, dryRun)
+	}, &report)
+	return report, err
+}
+
+// reconcileBatches runs fix over ids in ranges of batch and
+// returns the number of rows it checked.
+func reconcileBatches(ctx context.Context, db *sql.DB, table string, batch int, fix func(int64, int64) ([]CounterDiscrepancy, int, error), report *ReconcileReport) (int, error) {
+	lastId, err := maxId(ctx, db, table)
+	if err != nil {
+		return 0, err
+	}
+	checked := 0
+	for from := int64(0); from < lastId; from += int64(batch) {
+		if err := ctx.Err(); err != nil {
+			return checked, err
+		}
+		to := min(from + int64(batch), lastId)
+		found, rows, err := fix(from, to)
+		if err != nil {
+			return checked, err
+		}
+		checked += rows
+		report.discrepancies = append(report.discrepancies, found...)
+	}
+	return checked, nil
+}
+
+func maxId(ctx context.Context, db *sql.DB, table string) (int64, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+	var id int64
+	query := fmt.Sprintf(`SELECT COALESCE(MAX(id), 0) FROM %s`, table)
+	err := db.QueryRowContext(ctx, query).Scan(&id)
+	if err != nil {
+		log.Errorf("failed to read last id of %s: %v", table, err)
+		return 0, fmt.Errorf("failed to read last id of %s: %w", table, mapDbError(err))
+	}
+	return id, nil
+}
+
+func reconcilePosts(ctx context.Context, db *sql.DB, from int64, to int64, dryRun bool) ([]CounterDiscrepancy, int, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+	query := `
+	WITH counted AS (
+		SELECT p.id, p.likes AS stored_likes, p.replies AS stored_replies,
+		       (SELECT COUNT(*) FROM likes l WHERE l.post_id = p.id) AS likes,
+		       (SELECT COUNT(*) FROM posts r WHERE r.parent_id = p.id) AS replies
+		FROM posts p
+		WHERE p.id > $1 AND p.id <= $2
+	), fixed AS (
+		UPDATE posts p
+		SET likes = c.likes, replies = c.replies
+		FROM counted c
+		WHERE p.id = c.id
+		AND (p.likes <> c.likes OR p.replies <> c.replies)
+		AND NOT $3
+		RETURNING p.id
+	)
+	SELECT id, stored_likes, likes, stored_replies, replies
+	FROM counted`
+	lock := `SELECT id FROM posts WHERE id > $1 AND id <= $2 ORDER BY id FOR UPDATE`
+	return findDiscrepancies(ctx, db, lock, query, "post", "likes", "replies", from, to, dryRun)
+}
+
+func reconcileUsers(ctx context.Context, db *sql.DB, from int64, to int64, dryRun bool) ([]CounterDiscrepancy, int, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+	query := `
+	WITH counted AS (
+		SELECT u.id, u.followers AS stored_followers, u.followed AS stored_followed,
+		       (SELECT COUNT(*) FROM follows f WHERE f.followed_id = u.id) AS followers,
+		       (SELECT COUNT(*) FROM follows f WHERE f.user_id = u.id) AS followed
+		FROM users u
+		WHERE u.id > $1 AND u.id <= $2
+	), fixed AS (
+		UPDATE users u
+		SET followers = c.followers, followed = c.followed
+		FROM counted c
+		WHERE u.id = c.id
+		AND (u.followers <> c.followers OR u.followed <> c.followed)
+		AND NOT $3
+		RETURNING u.id
+	)
+	SELECT id, stored_followers, followers, stored_followed, followed
+	FROM counted`
+	lock := `SELECT id FROM users WHERE id > $1 AND id <= $2 ORDER BY id FOR UPDATE`
+	return findDiscrepancies(ctx, db, lock, query, "user", "followers", "followed", from, to, dryRun)
+}
+
+// findDiscrepancies runs a query returning the stored and
+// actual counters of every row checked, and keeps the ones
+// that differ. Unless dryRun, the batch is locked first, so the
+// counts are taken after writers holding it commit and none can
+// change a counter before the fix is written.
+func findDiscrepancies(ctx context.Context, db *sql.DB, lock string, query string, table string, first string, second string, from int64, to int64, dryRun bool) ([]CounterDiscrepancy, int, error) {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		log.Errorf("failed to start transaction: %v", err)
+		return nil, 0, fmt.Errorf("failed to start transaction: %w", mapDbError(err))
+	}
+	defer tx.Rollback()
+
+	if !dryRun {
+		if _, err := tx.ExecContext(ctx, lock, from, to); err != nil {
+			log.Errorf("failed to lock %s counters: %v", table, err)
+			return nil, 0, fmt.Errorf("failed to lock %s counters: %w", table, mapDbError(err))
+		}
+	}
+	rows, err := tx.QueryContext(ctx, query, from, to, dryRun)
+	if err != nil {
+		log.Errorf("failed to reconcile %s counters: %v", table, err)
+		return nil, 0, fmt.Errorf("failed to reconcile %s counters: %w", table, mapDbError(err))
+	}
+	defer rows.Close()
+
+	var found []CounterDiscrepancy
+	checked := 0
+	for rows.Next() {
+		var id int64
+		var stored, actual, storedSecond, actualSecond int
+		if err := rows.Scan(&id, &stored, &actual, &storedSecond, &actualSecond); err != nil {
+			return nil, 0, mapDbError(err)
+		}
+		checked += 1
+		if stored != actual {
+			found = append(found, CounterDiscrepancy{table, id, first, stored, actual})
+		}
+		if storedSecond != actualSecond {
+			found = append(found, CounterDiscrepancy{table, id, second, storedSecond, actualSecond})
+		}
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, 0, mapDbError(err)
+	}
+	rows.Close()
+
+	if err := tx.Commit(); err != nil {
+		log.Errorf("failed to commit %s counters: %v", table, err)
+		return nil, 0, fmt.Errorf("failed to commit %s counters: %w", table, mapDbError(err))
+	}
+	return found, checked, nil
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/charmbracelet/log"
+)
+
+// StartReconciler fixes drifted counters every interval until
+// the returned function is called.
+func StartReconciler(db *sql.DB, interval time.Duration, batch int) func() {
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				report, err := Reconcile(ctx, db, batch, false)
+				if err != nil {
+					log.Error("Counter reconciliation failed", "error", err)
+					continue
+				}
+				logReconcileReport(report, false)
+			}
+		}
+	}()
+	return func() {
+		cancel()
+		<-done
+	}
+}
+
+func logReconcileReport(report ReconcileReport, dryRun bool) {
+	for _, d := range report.discrepancies {
+		log.Warn("Counter drifted", "discrepancy", d.String())
+	}
+	log.Info("Counters reconciled",
+		"posts", report.posts,
+		"users", report.users,
+		"discrepancies", len(report.discrepancies),
+		"dryRun", dryRun,
+	)
+}
@@ -28,7 +28,11 @@ type RegisterOneModel struct {
 
 func getPageOneModel(renderer *lipgloss.Renderer, steps int, username string) RegisterOneModel {
 	nameInput := CreateCustomInput(renderer, "User name", "name", nameValidator, true)
-	nameInput.Input.SetValue(username)
+	// A login name with control characters can't be shown, nor
+	// registered, so the user has to pick another one.
+	if !hasControlCharacters(username) {
+		nameInput.Input.SetValue(username)
+	}
 	emailInput := CreateCustomInput(renderer, "E-mail", "mail", emailValidator, false)
 	birthInput := CreateCustomInput(renderer, "Birth date", "yyyy-mm-dd", dateValidator, false)
 
@@ -174,6 +178,9 @@ func nameValidator(s string) error {
 	if (len(s) > 20) {
 		return fmt.Errorf("at most 20 characters")
 	}
+	if hasControlCharacters(s) {
+		return fmt.Errorf("no control characters")
+	}
 	return nil
 }
 
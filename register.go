@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"database/sql"
 	"strings"
 	"time"
@@ -10,7 +11,7 @@ import (
 	"github.com/charmbracelet/log"
 )
 
-func getRegisterModel(renderer *lipgloss.Renderer, db *sql.DB, username string, publicKey string) (RegisterModel) {
+func getRegisterModel(ctx context.Context, renderer *lipgloss.Renderer, db *sql.DB, username string, publicKey string, remoteIp string) (RegisterModel) {
 	txtStyle := renderer.NewStyle().Foreground(lipgloss.Color("10"))
 	quitStyle := renderer.NewStyle().Foreground(lipgloss.Color("8"))
 	usernameStyle := renderer.NewStyle().Foreground(lipgloss.Color("5"))
@@ -44,7 +45,11 @@ func getRegisterModel(renderer *lipgloss.Renderer, db *sql.DB, username string,
 		currentView: 0,
 		pages: pages,
 		publicKey: publicKey,
+		remoteIp: remoteIp,
 		db: db,
+		ctx: ctx,
+		banner: CreateErrorBanner(renderer),
+		loader: CreateLoader(renderer),
 	}
 }
 
@@ -52,6 +57,7 @@ type RegisterModel struct {
 	name         string
 	username     string
 	publicKey    string
+	remoteIp     string
 	txtStyle     lipgloss.Style
 	quitStyle    lipgloss.Style
 	userStyle    lipgloss.Style
@@ -61,6 +67,14 @@ type RegisterModel struct {
 	currentView  int
 	pages        []tea.Model
 	db           *sql.DB
+	ctx          context.Context
+	banner       ErrorBanner
+	loader       Loader
+	registered   bool
+}
+
+type RegisteredMsg struct {
+	err  error
 }
 
 func (m RegisterModel) Init() tea.Cmd {
@@ -72,6 +86,8 @@ func (m RegisterModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	focused := m.CheckFocus()
 
 	switch msg := msg.(type) {
+	case DrainMsg:
+		return m, tea.Quit
 	case tea.KeyMsg:
 		switch msg.String() {
 		case "q", "ctrl+c":
@@ -99,10 +115,29 @@ func (m RegisterModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			return m, nil
 		}
 	case AcceptMsg:
-		m.Register()
+		if m.registered || m.loader.Loading() {
+			return m, nil
+		}
+		register := m.Register()
+		if register == nil {
+			return m, nil
+		}
+		cmd = tea.Batch(m.loader.Start("Creating account..."), register)
+		return m, cmd
+	case RegisteredMsg:
+		m.loader.Done()
+		if msg.err != nil {
+			m.banner.Show("Could not create your account", msg.err)
+			return m, nil
+		}
+		m.banner.Clear()
+		m.registered = true
+		return m, nil
 	}
-	m.pages[m.currentView], cmd = m.pages[m.currentView].Update(msg)
-	return m, cmd
+	var cmds []tea.Cmd = make([]tea.Cmd, 2)
+	m.loader, cmds[0] = m.loader.Update(msg)
+	m.pages[m.currentView], cmds[1] = m.pages[m.currentView].Update(msg)
+	return m, tea.Batch(cmds...)
 }
 
 func (m RegisterModel) CheckFocus() (bool) {
@@ -114,7 +149,7 @@ func (m RegisterModel) CheckFocus() (bool) {
 	}
 }
 
-func (m RegisterModel) Register() {
+func (m RegisterModel) Register() tea.Cmd {
 	log.Info("Trying to register")
 	view := m.pages[0]
 	var username string
@@ -127,12 +162,22 @@ func (m RegisterModel) Register() {
 		var err error
 		birthDate, err = time.Parse("2006-01-02", date)
 		if err != nil {
-			return
+			return nil
 		}
 	} else {
-		return 
+		return nil
+	}
+	ctx, db, publicKey, remoteIp := m.ctx, m.db, m.publicKey, m.remoteIp
+	return func() tea.Msg {
+		if err := registerLimiter.check("ip:" + remoteIp); err != nil {
+			return RegisteredMsg{err: err}
+		}
+		_, err := SaveUser(ctx, db, publicKey, username, email, birthDate)
+		if err == nil {
+			NotifyUserRegistered(ctx, username)
+		}
+		return RegisteredMsg{err: err}
 	}
-	SaveUser(m.db, m.publicKey, username, email, birthDate)
 }
 
 func (m RegisterModel) UpdatePageThree()  {
@@ -171,10 +216,19 @@ func (m RegisterModel) View() string {
 	}
 	
 	b.WriteString("\n")
-	b.WriteString(
-		m.pageStyle.Width(35).Render(m.pages[m.currentView].View()),
-	)
-	b.WriteString("\n\n")
+	if m.registered {
+		b.WriteString(
+			m.pageStyle.Width(35).Render("Account created. A moderator has to verify it before you can log in."),
+		)
+	} else {
+		b.WriteString(
+			m.pageStyle.Width(35).Render(m.pages[m.currentView].View()),
+		)
+	}
+	b.WriteString("\n")
+	b.WriteString(m.loader.View())
+	b.WriteString(m.banner.View())
+	b.WriteString("\n")
 	b.WriteString(m.quitStyle.Render("h/l ←/→ page • q: quit\n"))
 	return b.String()
 }
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/charmbracelet/log"
+)
+
+// Report is a post flagged by a user for moderators.
+type Report struct {
+	id        int64
+	postId    int64
+	author    string
+	content   string
+	reporter  string
+	createdAt time.Time
+}
+
+func CreateReportTable(db *sql.DB) {
+	createTableSQL := `
+	CREATE TABLE IF NOT EXISTS reports (
+		id SERIAL PRIMARY KEY,
+		post_id INTEGER REFERENCES posts(id) ON DELETE CASCADE,
+		user_id INTEGER REFERENCES users(id) ON DELETE CASCADE,
+		created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+		CONSTRAINT unique_report UNIQUE (user_id, post_id)
+	);`
+
+	_, err := db.Exec(createTableSQL)
+	if err != nil {
+		log.Fatalf("Failed to create table: %v", err)
+	}
+
+	log.Info("Table 'reports' created successfully!")
+}
+
+func SaveReport(ctx context.Context, db *sql.DB, user SavedUser, post Post) (int64, error) {
+	if err := checkWritable(); err != nil {
+		return 0, err
+	}
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+	log.Info("Saving report to db", "post", post.id, "user", user.username)
+	var id int64
+	query := `INSERT INTO reports (post_id, user_id) VALUES ($1, $2) RETURNING id`
+	err := db.QueryRowContext(ctx, query, post.id, user.id).Scan(&id)
+	if err != nil {
+		err = mapDbError(err)
+		if IsDomainError(err) {
+			log.Warn(err)
+			return 0, err
+		}
+		log.Errorf("failed to insert report: %v", err)
+		return 0, fmt.Errorf("failed to insert report: %w", err)
+	}
+	return id, nil
+}
+
+func FindReports(ctx context.Context, db *sql.DB) ([]Report, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+	query := `
+	SELECT r.id, r.post_id, a.username, p.content, u.username, r.created_at
+	FROM reports r
+	INNER JOIN posts p ON p.id = r.post_id
+	INNER JOIN users a ON a.id = p.user_id
+	INNER JOIN users u ON u.id = r.user_id
+	ORDER BY r.created_at DESC
+	LIMIT 100`
+	rows, err := db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, mapDbError(err)
+	}
+	defer rows.Close()
+
+	var reports []Report
+	for rows.Next() {
+		var r Report
+		if err := rows.Scan(&r.id, &r.postId, &r.author, &r.content, &r.reporter, &r.createdAt); err != nil {
+			return nil, mapDbError(err)
+		}
+		reports = append(reports, r)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, mapDbError(err)
+	}
+
+	return reports, nil
+}
+
+// DismissReports drops every report of a post, once it was
+// looked at.
+func DismissReports(ctx context.Context, db *sql.DB, postId int64) error {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+	query := `DELETE FROM reports WHERE post_id = $1`
+	_, err := db.ExecContext(ctx, query, postId)
+	if err != nil {
+		log.Errorf("failed to dismiss reports: %v", err)
+		return fmt.Errorf("failed to dismiss reports: %w", mapDbError(err))
+	}
+	return nil
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"strings"
+	"unicode"
+)
+
+type sanitizerState int
+
+const (
+	groundState sanitizerState = iota
+	escapeState sanitizerState = iota
+	csiState sanitizerState = iota
+	stringState sanitizerState = iota
+)
+
+// Sanitize removes terminal escape sequences (CSI, OSC, DCS and friends,
+// in both their 7-bit and C1 forms) and control characters from
+// user-provided text, so it can be safely written to someone's terminal.
+// Newlines and tabs are kept.
+func Sanitize(s string) string {
+	var b strings.Builder
+	b.Grow(len(s))
+	state := groundState
+	afterEscape := false
+
+	for _, r := range s {
+		switch state {
+		case groundState:
+			switch {
+			case r == 0x1b:
+				state = escapeState
+			case r == 0x9b:
+				state = csiState
+			case r == 0x90 || r == 0x98 || r == 0x9d || r == 0x9e || r == 0x9f:
+				state = stringState
+				afterEscape = false
+			case r == '\n' || r == '\t':
+				b.WriteRune(r)
+			case r < 0x20 || r == 0x7f || (r >= 0x80 && r <= 0x9f):
+			default:
+				b.WriteRune(r)
+			}
+		case escapeState:
+			switch {
+			case r == '[':
+				state = csiState
+			case r == ']' || r == 'P' || r == 'X' || r == '^' || r == '_':
+				state = stringState
+				afterEscape = false
+			case r >= 0x20 && r <= 0x2f:
+				// intermediate bytes, e.g. ESC ( B
+			default:
+				state = groundState
+			}
+		case csiState:
+			if r < 0x20 || r > 0x3f {
+				state = groundState
+			}
+		case stringState:
+			if r == 0x07 || r == 0x9c || (afterEscape && r == '\\') {
+				state = groundState
+			}
+			afterEscape = r == 0x1b
+		}
+	}
+	return b.String()
+}
+
+// SanitizeLine works like Sanitize but also turns newlines and tabs
+// into spaces, for single-line fields like usernames and locations.
+func SanitizeLine(s string) string {
+	return strings.Map(func(r rune) rune {
+		if r == '\n' || r == '\t' {
+			return ' '
+		}
+		return r
+	}, Sanitize(s))
+}
+
+// hasControlCharacters tells whether s contains anything
+// Sanitize would remove or SanitizeLine would replace.
+func hasControlCharacters(s string) bool {
+	return strings.IndexFunc(s, unicode.IsControl) >= 0
+}
@@ -0,0 +1,67 @@
+package main
+
+import "testing"
+
+func TestSanitize(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  string
+	}{
+		{"plain text", "hello, world", "hello, world"},
+		{"newline and tab kept", "one\ntwo\tthree", "one\ntwo\tthree"},
+		{"CSI clear screen", "a\x1b[2Jb", "ab"},
+		{"CSI with parameters", "\x1b[1;31mred\x1b[0m", "red"},
+		{"OSC title ended by BEL", "a\x1b]0;title\x07b", "ab"},
+		{"OSC title ended by ST", "a\x1b]0;title\x1b\\b", "ab"},
+		{"DCS ended by ST", "a\x1bPq#0;2;0;0;0\x1b\\b", "ab"},
+		{"charset designation", "a\x1b(Bb", "ab"},
+		{"C1 CSI", "a\u009b2Jb", "ab"},
+		{"C1 OSC ended by C1 ST", "a\u009d0;title\u009cb", "ab"},
+		{"C1 DCS ended by C1 ST", "a\u0090q#0\u009cb", "ab"},
+		{"other C1 controls", "a\u0085\u008eb", "ab"},
+		{"C0 controls", "a\x00\x07\x08\rb\x7f", "ab"},
+		{"unterminated CSI", "a\x1b[12;", "a"},
+		{"unterminated OSC", "a\x1b]0;title", "a"},
+		{"unterminated C1 DCS", "a\u0090data", "a"},
+		{"lone escape", "a\x1b", "a"},
+		{"non-ASCII text kept", "zażółć 🙂", "zażółć 🙂"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := Sanitize(test.input); got != test.want {
+				t.Errorf("Sanitize(%q) = %q, want %q", test.input, got, test.want)
+			}
+		})
+	}
+}
+
+func TestSanitizeLine(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  string
+	}{
+		{"newline and tab replaced", "one\ntwo\tthree", "one two three"},
+		{"CSI removed", "\x1b[2Jname", "name"},
+		{"C1 OSC removed", "\u009d0;title\u009cname", "name"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := SanitizeLine(test.input); got != test.want {
+				t.Errorf("SanitizeLine(%q) = %q, want %q", test.input, got, test.want)
+			}
+		})
+	}
+}
+
+func TestNameValidatorRejectsControlCharacters(t *testing.T) {
+	for _, name := range []string{"alice\x1b[2J", "alice\u009b", "ali\tce", "alice\n"} {
+		if err := nameValidator(name); err == nil {
+			t.Errorf("nameValidator(%q) accepted a control character", name)
+		}
+	}
+	if err := nameValidator("alice_01"); err != nil {
+		t.Errorf("nameValidator(%q) = %v", "alice_01", err)
+	}
+}
@@ -1,16 +1,16 @@
 package main
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
 	"strings"
 
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/charmbracelet/lipgloss"
-	"github.com/charmbracelet/log"
 )
 
-func getSearchView(renderer *lipgloss.Renderer, db *sql.DB, user SavedUser) (Tab) {
+func getSearchView(parent context.Context, renderer *lipgloss.Renderer, db *sql.DB, user SavedUser) (Tab) {
 	infoWidth := 20
 	infoStyle := renderer.NewStyle().
 		MaxWidth(infoWidth).
@@ -33,9 +33,14 @@ func getSearchView(renderer *lipgloss.Renderer, db *sql.DB, user SavedUser) (Tab
 
 	nameInput := CreateCustomInput(renderer, "Search", "name", searchQueryValidator, true)
 
+	ctx, cancel := context.WithCancel(parent)
+
 
 	return Tab{
 		Model: SearchViewModel{ 
+			id: nextTabId(),
+			ctx: ctx,
+			cancel: cancel,
 			infoStyle: infoStyle, 
 			quitStyle: quitStyle,
 			postStyle: postStyle,
@@ -49,6 +54,8 @@ func getSearchView(renderer *lipgloss.Renderer, db *sql.DB, user SavedUser) (Tab
 			input: false,
 			current: 0,
 			inList: false,
+			banner: CreateErrorBanner(renderer),
+			loader: CreateLoader(renderer),
 		},
 		Name: "Search",
 	}
@@ -56,6 +63,9 @@ func getSearchView(renderer *lipgloss.Renderer, db *sql.DB, user SavedUser) (Tab
 
 
 type SearchViewModel struct {
+	id           int64
+	ctx          context.Context
+	cancel       context.CancelFunc
 	infoStyle    lipgloss.Style
 	quitStyle    lipgloss.Style
 	postStyle    lipgloss.Style
@@ -71,12 +81,34 @@ type SearchViewModel struct {
 	users        []SavedUser
 	current      int
 	inList       bool
+	banner       ErrorBanner
+	loader       Loader
+}
+
+type SearchResultMsg struct {
+	tab    int64
+	users  []SavedUser
+	err    error
+}
+
+func searchUsers(ctx context.Context, tab int64, db *sql.DB, query string) tea.Cmd {
+	return func() tea.Msg {
+		if ctx.Err() != nil {
+			return nil
+		}
+		users, err := SearchUsers(ctx, db, query)
+		return SearchResultMsg{tab: tab, users: users, err: err}
+	}
 }
 
 func (m SearchViewModel) Init() tea.Cmd {
 	return nil
 }
 
+func (m SearchViewModel) Close() {
+	m.cancel()
+}
+
 func (m SearchViewModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	switch msg := msg.(type) {
 	case tea.WindowSizeMsg: 
@@ -104,13 +136,11 @@ func (m SearchViewModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 				m.nameInput.Blur()
 				searchQuery := m.nameInput.Input.Value()
 				m.input = false;
-				users, err := SearchUsers(m.db, searchQuery)
-				if (err == nil) {
-					m.users =  users
-				} else {
-					log.Info(err)
-				}
-				return m, nil
+				cmd := tea.Batch(
+					m.loader.Start("Searching..."),
+					searchUsers(m.ctx, m.id, m.db, searchQuery),
+				)
+				return m, cmd
 			}
 		case "j", "down": 
 		        if(!m.input) {
@@ -129,17 +159,40 @@ func (m SearchViewModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 				return m, nil
 			}
 		}
+	case SearchResultMsg:
+		if msg.tab != m.id {
+			return m, nil
+		}
+		m.loader.Done()
+		if (msg.err == nil) {
+			m.users = msg.users
+			m.current = 0
+			m.inList = false
+			m.banner.Clear()
+		} else {
+			m.banner.Show("Search failed", msg.err)
+		}
+		return m, nil
 	}
 
-	var cmd tea.Cmd
-	m.nameInput, cmd = m.nameInput.Update(msg)
-	return m, cmd
+	var cmds []tea.Cmd = make([]tea.Cmd, 2)
+	m.loader, cmds[0] = m.loader.Update(msg)
+	m.nameInput, cmds[1] = m.nameInput.Update(msg)
+	return m, tea.Batch(cmds...)
 }
 
 func (m SearchViewModel) View() string {
 	doc := strings.Builder{}
 	name := m.nameInput.View(false)
 	doc.WriteString(name)
+	if m.loader.Height() > 0 {
+		doc.WriteString("\n")
+		doc.WriteString(m.loader.View())
+	}
+	if m.banner.Height() > 0 {
+		doc.WriteString("\n")
+		doc.WriteString(m.banner.View())
+	}
 	if (len(m.users) != 0) {
 		doc.WriteString("\n")
 		doc.WriteString(m.quitStyle.Render("Results"))
@@ -150,7 +203,7 @@ func (m SearchViewModel) View() string {
 			} else {
 				doc.WriteString(" ")
 			}
-			doc.WriteString(user.username)
+			doc.WriteString(SanitizeLine(user.username))
 		}
 	} else {
 		doc.WriteString("\n")
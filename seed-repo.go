@@ -0,0 +1,76 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/charmbracelet/log"
+)
+
+// SaveSeed inserts generated data in one transaction. Like
+// ImportArchive it leaves counters alone; run Reconcile
+// afterwards.
+func SaveSeed(ctx context.Context, db *sql.DB, seed Seed) error {
+	log.Info("Seeding database", "users", len(seed.users), "posts", len(seed.posts))
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		log.Errorf("failed to start transaction: %v", err)
+		return fmt.Errorf("failed to start transaction: %w", mapDbError(err))
+	}
+	defer tx.Rollback()
+
+	userIds := make([]int64, len(seed.users))
+	for i, user := range seed.users {
+		query := `
+		INSERT INTO users (key, username, email, verified, administrator, birth_date, created_at)
+		VALUES ($1, $2, $3, true, false, $4, $5)
+		RETURNING id`
+		err = queryRowTx(ctx, tx, query, []any{&userIds[i]},
+			user.key, user.username, user.email, user.birthDate, user.createdAt)
+		if err != nil {
+			log.Errorf("failed to seed user: %v", err)
+			return fmt.Errorf("failed to seed user %s: %w", user.username, mapDbError(err))
+		}
+	}
+
+	postIds := make([]int64, len(seed.posts))
+	for i, post := range seed.posts {
+		var parentId sql.NullInt64
+		if post.parent >= 0 {
+			parentId = sql.NullInt64{Int64: postIds[post.parent], Valid: true}
+		}
+		query := `
+		INSERT INTO posts (content, content_warning, user_id, created_at, parent_id)
+		VALUES ($1, NULLIF($2, ''), $3, $4, $5)
+		RETURNING id`
+		err = queryRowTx(ctx, tx, query, []any{&postIds[i]},
+			post.content, post.contentWarning, userIds[post.author], post.createdAt, parentId)
+		if err != nil {
+			log.Errorf("failed to seed post: %v", err)
+			return fmt.Errorf("failed to seed post: %w", mapDbError(err))
+		}
+	}
+
+	for _, like := range seed.likes {
+		query := `INSERT INTO likes (user_id, post_id, liked_at) VALUES ($1, $2, $3)`
+		if _, err = execTx(ctx, tx, query, userIds[like.user], postIds[like.post], like.likedAt); err != nil {
+			log.Errorf("failed to seed like: %v", err)
+			return fmt.Errorf("failed to seed like: %w", mapDbError(err))
+		}
+	}
+
+	for _, follow := range seed.follows {
+		query := `INSERT INTO follows (user_id, followed_id) VALUES ($1, $2)`
+		if _, err = execTx(ctx, tx, query, userIds[follow.user], userIds[follow.followed]); err != nil {
+			log.Errorf("failed to seed follow: %v", err)
+			return fmt.Errorf("failed to seed follow: %w", mapDbError(err))
+		}
+	}
+
+	if err = tx.Commit(); err != nil {
+		log.Errorf("failed to commit seed: %v", err)
+		return fmt.Errorf("failed to commit seed: %w", mapDbError(err))
+	}
+	return nil
+}
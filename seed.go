@@ -0,0 +1,262 @@
+package main
+
+import (
+	"crypto/ed25519"
+	"fmt"
+	"math"
+	"math/rand"
+	"sort"
+	"strings"
+	"time"
+
+	gossh "golang.org/x/crypto/ssh"
+)
+
+// SeedOptions describe the data made by GenerateSeed. The same
+// options always give the same data.
+type SeedOptions struct {
+	seed    int64
+	users   int
+	posts   int
+	replies float64
+	likes   int
+	follows int
+	days    int
+	until   time.Time
+}
+
+type SeedUser struct {
+	username   string
+	email      string
+	key        string
+	privateKey ed25519.PrivateKey
+	birthDate  time.Time
+	createdAt  time.Time
+	activity   float64
+}
+
+// SeedPost points at its author and parent by index; a parent
+// always comes before its replies.
+type SeedPost struct {
+	author         int
+	content        string
+	contentWarning string
+	createdAt      time.Time
+	parent         int
+}
+
+type SeedLike struct {
+	user    int
+	post    int
+	likedAt time.Time
+}
+
+type SeedFollow struct {
+	user     int
+	followed int
+}
+
+type Seed struct {
+	users   []SeedUser
+	posts   []SeedPost
+	likes   []SeedLike
+	follows []SeedFollow
+}
+
+func (s Seed) Replies() int {
+	replies := 0
+	for _, post := range s.posts {
+		if post.parent >= 0 {
+			replies += 1
+		}
+	}
+	return replies
+}
+
+var (
+	seedNames = []string{
+		"alice", "bob", "carol", "dave", "erin", "frank", "grace", "heidi",
+		"ivan", "judy", "mallory", "niaj", "olivia", "peggy", "rupert", "sybil",
+		"trent", "victor", "walter", "yvonne",
+	}
+	seedWords = []string{
+		"just", "shipped", "a", "new", "release", "of", "my", "terminal", "app",
+		"coffee", "is", "the", "only", "thing", "keeping", "me", "going", "today",
+		"anyone", "else", "think", "vim", "keybindings", "everywhere", "are", "great",
+		"finally", "fixed", "that", "bug", "after", "three", "days", "reading", "logs",
+		"what", "should", "I", "learn", "next", "weekend", "hiking", "was", "amazing",
+		"writing", "more", "tests", "deploying", "on", "friday", "again", "why",
+		"not", "ssh", "into", "everything", "lunch", "break", "with", "good", "music",
+	}
+	seedHashtags = []string{"#golang", "#ssh", "#terminal", "#coffee", "#weekend", "#devlife", "#opensource"}
+	seedWarnings = []string{"spoilers", "politics", "food", "long rant"}
+	// hourWeights shape when users post: little at night, a peak
+	// in the evening.
+	hourWeights = []float64{
+		2, 1, 1, 1, 1, 2, 3, 5, 7, 8, 8, 8,
+		9, 9, 8, 8, 8, 9, 10, 11, 11, 10, 7, 4,
+	}
+)
+
+// GenerateSeed makes users with ed25519 keys, their posts, reply
+// trees, likes and follows. A few users are far more active than
+// the rest, and follows and likes favour them.
+func GenerateSeed(options SeedOptions) Seed {
+	rng := rand.New(rand.NewSource(options.seed))
+	span := time.Duration(options.days) * 24 * time.Hour
+	start := options.until.Add(-span)
+	var seed Seed
+
+	for i := 0; i < options.users; i++ {
+		keySeed := make([]byte, ed25519.SeedSize)
+		rng.Read(keySeed)
+		privateKey := ed25519.NewKeyFromSeed(keySeed)
+		publicKey, _ := gossh.NewPublicKey(privateKey.Public())
+		name := seedNames[i%len(seedNames)]
+		seed.users = append(seed.users, SeedUser{
+			username:   fmt.Sprintf("%s_%04d", name, i+1),
+			email:      fmt.Sprintf("%s.%d@example.com", name, i+1),
+			key:        ConvertKey(publicKey),
+			privateKey: privateKey,
+			birthDate:  time.Date(1960+rng.Intn(45), time.Month(1+rng.Intn(12)), 1+rng.Intn(28), 0, 0, 0, 0, time.UTC),
+			createdAt:  start.Add(time.Duration(rng.Int63n(int64(span/4) + 1))),
+			// Pareto distributed, so activity is very uneven.
+			activity: math.Min(1/math.Pow(1-rng.Float64(), 1/1.2), 100),
+		})
+	}
+	if len(seed.users) == 0 {
+		return seed
+	}
+	activity := make([]float64, len(seed.users))
+	for i, user := range seed.users {
+		activity[i] = user.activity
+	}
+	pickUser := weightedPicker(rng, activity)
+
+	total := options.users * options.posts
+	replies := int(float64(total) * options.replies)
+	for i := 0; i < total-replies; i++ {
+		author := pickUser()
+		seed.posts = append(seed.posts, SeedPost{
+			author:         author,
+			content:        seedContent(rng),
+			contentWarning: seedWarning(rng),
+			createdAt:      seedTime(rng, seed.users[author].createdAt, options.until),
+			parent:         -1,
+		})
+	}
+	sort.SliceStable(seed.posts, func(i, j int) bool {
+		return seed.posts[i].createdAt.Before(seed.posts[j].createdAt)
+	})
+
+	for i := 0; i < replies && len(seed.posts) > 0; i++ {
+		// Recent posts get more replies, and replies can be
+		// answered too, which makes threads.
+		parent := len(seed.posts) - 1 - int(math.Min(rng.ExpFloat64()*float64(len(seed.posts))/4, float64(len(seed.posts)-1)))
+		author := pickUser()
+		if author == seed.posts[parent].author {
+			author = (author + 1) % len(seed.users)
+		}
+		delay := time.Duration(rng.ExpFloat64() * float64(2*time.Hour))
+		createdAt := seed.posts[parent].createdAt.Add(time.Minute + delay)
+		if createdAt.After(options.until) || seed.users[author].createdAt.After(createdAt) {
+			continue
+		}
+		seed.posts = append(seed.posts, SeedPost{
+			author:    author,
+			content:   seedContent(rng),
+			createdAt: createdAt,
+			parent:    parent,
+		})
+	}
+
+	for user := range seed.users {
+		followed := map[int]bool{user: true}
+		for n := rng.Intn(2*options.follows + 1); n > 0 && len(followed) < len(seed.users); n-- {
+			other := pickUser()
+			if followed[other] {
+				continue
+			}
+			followed[other] = true
+			seed.follows = append(seed.follows, SeedFollow{user: user, followed: other})
+		}
+	}
+
+	if len(seed.posts) > 0 {
+		authorActivity := make([]float64, len(seed.posts))
+		for i, post := range seed.posts {
+			authorActivity[i] = seed.users[post.author].activity
+		}
+		pickPost := weightedPicker(rng, authorActivity)
+		for user := range seed.users {
+			liked := map[int]bool{}
+			for n := rng.Intn(2*options.likes + 1); n > 0; n-- {
+				post := pickPost()
+				if liked[post] || seed.posts[post].author == user {
+					continue
+				}
+				liked[post] = true
+				delay := time.Duration(rng.ExpFloat64() * float64(time.Hour))
+				likedAt := seed.posts[post].createdAt
+				if seed.users[user].createdAt.After(likedAt) {
+					likedAt = seed.users[user].createdAt
+				}
+				likedAt = likedAt.Add(delay)
+				if likedAt.After(options.until) {
+					likedAt = options.until
+				}
+				seed.likes = append(seed.likes, SeedLike{user: user, post: post, likedAt: likedAt})
+			}
+		}
+	}
+	return seed
+}
+
+// weightedPicker returns indexes of weights with probability
+// proportional to their weight.
+func weightedPicker(rng *rand.Rand, weights []float64) func() int {
+	cumulative := make([]float64, len(weights))
+	sum := 0.0
+	for i, w := range weights {
+		sum += w
+		cumulative[i] = sum
+	}
+	return func() int {
+		return sort.SearchFloat64s(cumulative, rng.Float64()*sum)
+	}
+}
+
+// seedTime picks a moment between from and until, following
+// hourWeights during the day.
+func seedTime(rng *rand.Rand, from time.Time, until time.Time) time.Time {
+	pickHour := weightedPicker(rng, hourWeights)
+	days := int(until.Sub(from).Hours()/24) + 1
+	for attempt := 0; attempt < 10; attempt++ {
+		day := from.Truncate(24 * time.Hour).AddDate(0, 0, rng.Intn(days))
+		t := day.Add(time.Duration(pickHour())*time.Hour + time.Duration(rng.Int63n(int64(time.Hour))))
+		if t.After(from) && t.Before(until) {
+			return t
+		}
+	}
+	return from.Add(time.Duration(rng.Int63n(int64(until.Sub(from)) + 1)))
+}
+
+func seedContent(rng *rand.Rand) string {
+	words := make([]string, 3+rng.Intn(18))
+	for i := range words {
+		words[i] = seedWords[rng.Intn(len(seedWords))]
+	}
+	content := strings.Join(words, " ")
+	content = strings.ToUpper(content[:1]) + content[1:]
+	if rng.Float64() < 0.15 {
+		content += " " + seedHashtags[rng.Intn(len(seedHashtags))]
+	}
+	return content
+}
+
+func seedWarning(rng *rand.Rand) string {
+	if rng.Float64() < 0.05 {
+		return seedWarnings[rng.Intn(len(seedWarnings))]
+	}
+	return ""
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func testSeedOptions() SeedOptions {
+	return SeedOptions{
+		seed:    42,
+		users:   30,
+		posts:   200,
+		replies: 0.4,
+		likes:   300,
+		follows: 100,
+		days:    14,
+		until:   time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC),
+	}
+}
+
+func TestGenerateSeedIsDeterministic(t *testing.T) {
+	first, second := GenerateSeed(testSeedOptions()), GenerateSeed(testSeedOptions())
+	if !reflect.DeepEqual(first, second) {
+		t.Error("two seeds generated with the same options differ")
+	}
+}
+
+func TestGenerateSeedInvariants(t *testing.T) {
+	seed := GenerateSeed(testSeedOptions())
+	if len(seed.users) == 0 || len(seed.posts) == 0 || len(seed.likes) == 0 || len(seed.follows) == 0 {
+		t.Fatalf("seed is missing data: %d users, %d posts, %d likes, %d follows",
+			len(seed.users), len(seed.posts), len(seed.likes), len(seed.follows))
+	}
+	if seed.Replies() == 0 {
+		t.Error("seed has no replies")
+	}
+	for i, post := range seed.posts {
+		if post.parent >= i {
+			t.Errorf("post %d replies to post %d, which comes after it", i, post.parent)
+		}
+	}
+	for _, like := range seed.likes {
+		if seed.posts[like.post].author == like.user {
+			t.Errorf("user %d likes their own post %d", like.user, like.post)
+		}
+	}
+	for _, follow := range seed.follows {
+		if follow.user == follow.followed {
+			t.Errorf("user %d follows themselves", follow.user)
+		}
+	}
+}
@@ -0,0 +1,132 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/charmbracelet/log"
+	"github.com/charmbracelet/ssh"
+	"github.com/charmbracelet/wish"
+)
+
+// commandMiddleware answers sessions started with a command,
+// like `ssh sshwitter export`, instead of opening the UI.
+func commandMiddleware(db *sql.DB) wish.Middleware {
+	return func(next ssh.Handler) ssh.Handler {
+		return func(s ssh.Session) {
+			command := s.Command()
+			if len(command) == 0 {
+				next(s)
+				return
+			}
+			log.Info("Session command", "user", s.User(), "command", strings.Join(command, " "))
+			switch command[0] {
+			case "export":
+				exportSessionCommand(s, db)
+			case "feed":
+				feedSessionCommand(s, db, command[1:])
+			case "readonly":
+				readOnlySessionCommand(s, command[1:])
+			default:
+				wish.Fatalln(s, fmt.Sprintf("unknown command %q", command[0]))
+			}
+		}
+	}
+}
+
+func sessionUser(s ssh.Session) (SavedUser, bool) {
+	guest, _ := s.Context().Value("guest").(bool)
+	verified, _ := s.Context().Value("verified").(bool)
+	user, ok := s.Context().Value("user").(SavedUser)
+	return user, ok && !guest && verified
+}
+
+func exportSessionCommand(s ssh.Session, db *sql.DB) {
+	user, ok := sessionUser(s)
+	if !ok {
+		wish.Fatalln(s, "only verified users can export their data")
+		return
+	}
+	user, err := GetUserByUsername(s.Context(), db, user.username)
+	if err != nil {
+		wish.Fatalln(s, ErrorMessage(err, "could not load your profile"))
+		return
+	}
+	archive, err := ExportArchive(s.Context(), db, user)
+	if err != nil {
+		wish.Fatalln(s, ErrorMessage(err, "could not export your data"))
+		return
+	}
+	encoder := json.NewEncoder(s)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(archive); err != nil {
+		log.Error("Could not write archive", "error", err)
+		s.Exit(1)
+		return
+	}
+	s.Exit(0)
+}
+
+// feedSessionCommand writes a public feed, like
+// `ssh sshwitter feed atom user alice` or `feed rss tag go`.
+func feedSessionCommand(s ssh.Session, db *sql.DB, args []string) {
+	usage := "usage: feed <atom|rss> <global|user NAME|tag NAME>"
+	if len(args) < 2 {
+		wish.Fatalln(s, usage)
+		return
+	}
+	format := FeedFormat(args[0])
+	if format != atomFormat && format != rssFormat {
+		wish.Fatalln(s, usage)
+		return
+	}
+	kind, name := args[1], ""
+	if (kind == "global") != (len(args) == 2) || len(args) > 3 {
+		wish.Fatalln(s, usage)
+		return
+	}
+	if len(args) == 3 {
+		name = args[2]
+	}
+	syndication, err := FindSyndication(s.Context(), db, kind, name)
+	if err != nil {
+		wish.Fatalln(s, ErrorMessage(err, "could not load the feed"))
+		return
+	}
+	if err := WriteSyndication(s, syndication, format); err != nil {
+		log.Error("Could not write feed", "error", err)
+		s.Exit(1)
+		return
+	}
+	s.Exit(0)
+}
+
+// readOnlySessionCommand shows or toggles read-only mode, like
+// `ssh sshwitter readonly on`. It doesn't query the database,
+// so it works while the database is under maintenance.
+func readOnlySessionCommand(s ssh.Session, args []string) {
+	user, ok := sessionUser(s)
+	if !ok || !user.administrator {
+		wish.Fatalln(s, "only administrators can change read-only mode")
+		return
+	}
+	if len(args) > 0 {
+		switch args[0] {
+		case "on":
+			SetReadOnly(true, user.username)
+		case "off":
+			SetReadOnly(false, user.username)
+		default:
+			wish.Fatalln(s, "usage: readonly [on|off]")
+			return
+		}
+	}
+	if ReadOnly() {
+		wish.Println(s, "read-only mode is on")
+	} else {
+		wish.Println(s, "read-only mode is off")
+	}
+	s.Exit(0)
+}
@@ -0,0 +1,277 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/xml"
+	"fmt"
+	"html"
+	"io"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type FeedFormat string
+
+const (
+	atomFormat FeedFormat = "atom"
+	rssFormat  FeedFormat = "rss"
+)
+
+const (
+	syndicationSize = 50
+	// tagDate is the date part of the tag: URIs used as ids, it
+	// must never change or readers would see every entry as new.
+	tagDate = "2024"
+)
+
+var feedBaseUrl = "http://localhost"
+
+func SetFeedBaseUrl(base string) {
+	feedBaseUrl = strings.TrimSuffix(base, "/")
+}
+
+var hashtagPattern = regexp.MustCompile(`^[\p{L}\p{N}_]{1,50}$`)
+
+// ValidHashtag reports whether tag, without the leading #, is
+// safe to match against post content.
+func ValidHashtag(tag string) bool {
+	return hashtagPattern.MatchString(tag)
+}
+
+// Syndication is a feed of posts ready to be written as Atom
+// or RSS. path identifies the feed, like "users/alice".
+type Syndication struct {
+	title  string
+	path   string
+	posts  []Post
+}
+
+// FindSyndication loads the newest posts of the global feed
+// ("global"), of a user ("user", name) or of a hashtag ("tag",
+// name).
+func FindSyndication(ctx context.Context, db *sql.DB, kind string, name string) (Syndication, error) {
+	var result Syndication
+	var posts []Post
+	var err error
+	switch kind {
+	case "global":
+		result = Syndication{title: "sshwitter", path: "global"}
+		posts, err = FindAllPostsPage(ctx, db, SavedUser{}, syndicationSize, 0)
+	case "user":
+		user, userErr := GetUserByUsername(ctx, db, name)
+		if userErr == nil && !user.verified {
+			userErr = ErrNotFound
+		}
+		if userErr != nil {
+			return result, userErr
+		}
+		result = Syndication{title: "Posts by " + user.username, path: "users/" + user.username}
+		posts, err = FindUserPostsPage(ctx, db, user, SavedUser{}, syndicationSize, 0)
+	case "tag":
+		tag := strings.TrimPrefix(name, "#")
+		if !ValidHashtag(tag) {
+			return result, ErrNotFound
+		}
+		tag = strings.ToLower(tag)
+		result = Syndication{title: "Posts tagged #" + tag, path: "tags/" + tag}
+		posts, err = FindHashtagPostsPage(ctx, db, tag, SavedUser{}, syndicationSize, 0)
+	default:
+		return result, ErrNotFound
+	}
+	if err != nil {
+		return result, err
+	}
+	result.posts = posts
+	return result, nil
+}
+
+func (s Syndication) updated() time.Time {
+	updated := time.Unix(0, 0)
+	for _, post := range s.posts {
+		if post.createdAt.After(updated) {
+			updated = post.createdAt
+		}
+	}
+	return updated.UTC()
+}
+
+func tagUri(path string) string {
+	host := "localhost"
+	if base, err := url.Parse(feedBaseUrl); err == nil && base.Hostname() != "" {
+		host = base.Hostname()
+	}
+	return fmt.Sprintf("tag:%s,%s:%s", host, tagDate, path)
+}
+
+func postUrl(post Post) string {
+	return feedBaseUrl + "/api/posts/" + strconv.FormatInt(post.id, 10)
+}
+
+func feedUrl(s Syndication, format FeedFormat) string {
+	return feedBaseUrl + "/feeds/" + s.path + "." + string(format)
+}
+
+// entryTitle never reveals content hidden behind a warning.
+func entryTitle(post Post) string {
+	if post.contentWarning.Valid && post.contentWarning.String != "" {
+		return post.username + ": CW " + post.contentWarning.String
+	}
+	line, _, _ := strings.Cut(post.content, "\n")
+	if runes := []rune(line); len(runes) > 60 {
+		line = string(runes[:60]) + "…"
+	}
+	return post.username + ": " + line
+}
+
+type atomLink struct {
+	Href string `xml:"href,attr"`
+	Rel  string `xml:"rel,attr,omitempty"`
+	Type string `xml:"type,attr,omitempty"`
+}
+
+type atomText struct {
+	Type string `xml:"type,attr"`
+	Body string `xml:",chardata"`
+}
+
+type atomAuthor struct {
+	Name string `xml:"name"`
+}
+
+type atomEntry struct {
+	Title     string     `xml:"title"`
+	Id        string     `xml:"id"`
+	Updated   string     `xml:"updated"`
+	Published string     `xml:"published"`
+	Author    atomAuthor `xml:"author"`
+	Link      atomLink   `xml:"link"`
+	Summary   *atomText  `xml:"summary,omitempty"`
+	Content   atomText   `xml:"content"`
+}
+
+type atomFeed struct {
+	XMLName xml.Name    `xml:"http://www.w3.org/2005/Atom feed"`
+	Title   string      `xml:"title"`
+	Id      string      `xml:"id"`
+	Updated string      `xml:"updated"`
+	Links   []atomLink  `xml:"link"`
+	Entries []atomEntry `xml:"entry"`
+}
+
+type rssGuid struct {
+	IsPermaLink string `xml:"isPermaLink,attr"`
+	Value       string `xml:",chardata"`
+}
+
+type rssItem struct {
+	Title       string  `xml:"title"`
+	Link        string  `xml:"link"`
+	Guid        rssGuid `xml:"guid"`
+	PubDate     string  `xml:"pubDate"`
+	Description string  `xml:"description"`
+}
+
+type rssChannel struct {
+	Title         string    `xml:"title"`
+	Link          string    `xml:"link"`
+	Description   string    `xml:"description"`
+	LastBuildDate string    `xml:"lastBuildDate"`
+	Items         []rssItem `xml:"item"`
+}
+
+type rssFeed struct {
+	XMLName xml.Name   `xml:"rss"`
+	Version string     `xml:"version,attr"`
+	Channel rssChannel `xml:"channel"`
+}
+
+func toAtom(s Syndication) atomFeed {
+	feed := atomFeed{
+		Title: s.title,
+		Id: tagUri("feeds/" + s.path),
+		Updated: s.updated().Format(time.RFC3339),
+		Links: []atomLink{
+			{Href: feedUrl(s, atomFormat), Rel: "self", Type: "application/atom+xml"},
+		},
+		Entries: make([]atomEntry, 0, len(s.posts)),
+	}
+	for _, post := range s.posts {
+		created := post.createdAt.UTC().Format(time.RFC3339)
+		entry := atomEntry{
+			Title: entryTitle(post),
+			Id: tagUri("posts/" + strconv.FormatInt(post.id, 10)),
+			Updated: created,
+			Published: created,
+			Author: atomAuthor{post.username},
+			Link: atomLink{Href: postUrl(post), Rel: "alternate", Type: "application/json"},
+			Content: atomText{Type: "text", Body: post.content},
+		}
+		if post.contentWarning.Valid && post.contentWarning.String != "" {
+			entry.Summary = &atomText{Type: "text", Body: post.contentWarning.String}
+		}
+		feed.Entries = append(feed.Entries, entry)
+	}
+	return feed
+}
+
+// toRss escapes post content as HTML before it's escaped again
+// as XML, since readers render RSS descriptions as HTML.
+func toRss(s Syndication) rssFeed {
+	feed := rssFeed{
+		Version: "2.0",
+		Channel: rssChannel{
+			Title: s.title,
+			Link: feedUrl(s, rssFormat),
+			Description: s.title,
+			LastBuildDate: s.updated().Format(time.RFC1123Z),
+			Items: make([]rssItem, 0, len(s.posts)),
+		},
+	}
+	for _, post := range s.posts {
+		description := strings.ReplaceAll(html.EscapeString(post.content), "\n", "<br>")
+		if post.contentWarning.Valid && post.contentWarning.String != "" {
+			description = "<p>CW: " + html.EscapeString(post.contentWarning.String) + "</p>" + description
+		}
+		feed.Channel.Items = append(feed.Channel.Items, rssItem{
+			Title: entryTitle(post),
+			Link: postUrl(post),
+			Guid: rssGuid{IsPermaLink: "false", Value: tagUri("posts/" + strconv.FormatInt(post.id, 10))},
+			PubDate: post.createdAt.UTC().Format(time.RFC1123Z),
+			Description: description,
+		})
+	}
+	return feed
+}
+
+func WriteSyndication(w io.Writer, s Syndication, format FeedFormat) error {
+	var doc any
+	switch format {
+	case atomFormat:
+		doc = toAtom(s)
+	case rssFormat:
+		doc = toRss(s)
+	default:
+		return fmt.Errorf("unknown feed format %q", format)
+	}
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	encoder := xml.NewEncoder(w)
+	encoder.Indent("", "  ")
+	if err := encoder.Encode(doc); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
+
+func (f FeedFormat) ContentType() string {
+	if f == rssFormat {
+		return "application/rss+xml; charset=utf-8"
+	}
+	return "application/atom+xml; charset=utf-8"
+}
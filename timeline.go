@@ -7,7 +7,6 @@ import (
 	"strings"
 	"time"
 
-	"github.com/charmbracelet/bubbles/textarea"
 	"github.com/charmbracelet/bubbles/viewport"
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/charmbracelet/lipgloss"
@@ -23,27 +22,23 @@ func getTimeline(renderer *lipgloss.Renderer, db *sql.DB, posts []Post, user Sav
 		Foreground(lipgloss.Color("5"))
 	numberStyle := quitStyle.
 		Bold(true)
-
-	textInput := textarea.New()
-	textInput.Placeholder = "Type a message..."
-
-	textInput.CharLimit = 280
-	textInput.SetWidth(30)
-	textInput.SetHeight(3)
-	textInput.FocusedStyle.CursorLine = lipgloss.NewStyle()
-	textInput.ShowLineNumbers = false
+	barStyle := renderer.NewStyle().
+		Foreground(lipgloss.Color("#1da1f2"))
 
 	return TimelineModel{ 
 		quitStyle: quitStyle,
 		postStyle: postStyle,
 		headerStyle: headerStyle,
 		numberStyle: numberStyle,
+		barStyle: barStyle,
+		formatter: getFormatter(renderer),
 		db: db,
 		posts: posts,
 		user: user,
 		currentPost: 0,
 		hasHighlight: false,
 		highlighted: 0,
+		toggled: make(map[int64]bool),
 	}
 }
 
@@ -53,6 +48,8 @@ type TimelineModel struct {
 	postStyle       lipgloss.Style
 	headerStyle     lipgloss.Style
 	numberStyle     lipgloss.Style
+	barStyle        lipgloss.Style
+	formatter       Formatter
 	posts           []Post
 	indices         []PostIndice
 	user            SavedUser
@@ -61,6 +58,9 @@ type TimelineModel struct {
 	currentPost     int
 	hasHighlight    bool
 	highlighted     int
+	// toggled holds the posts whose warning was flipped from the
+	// user's default with e.
+	toggled         map[int64]bool
 }
 
 type PostIndice struct {
@@ -147,7 +147,7 @@ func (m TimelineModel) postView(post Post, index int) string {
 	last := index + 1 < len(m.posts)
 	highlighted := m.hasHighlight && m.highlighted == index
 	doc := strings.Builder{}
-	doc.WriteString(m.headerStyle.Render(post.username))
+	doc.WriteString(m.headerStyle.Render(SanitizeLine(post.username)))
 	doc.WriteString(m.quitStyle.Render(" · "))
 	doc.WriteString(m.quitStyle.Render(RelativeTime(post.createdAt)))
 	if current {
@@ -155,12 +155,27 @@ func (m TimelineModel) postView(post Post, index int) string {
 	}
 	doc.WriteString("\n")
 
-	if (highlighted) {
-		doc.WriteString(m.headerStyle.Render(post.content))
-	} else {
-		doc.WriteString(post.content)
+	collapsed := false
+	if post.contentWarning.Valid {
+		doc.WriteString(m.headerStyle.Render("CW: " + SanitizeLine(post.contentWarning.String)))
+		collapsed = !m.Expanded(post)
+		if collapsed {
+			doc.WriteString(m.quitStyle.Render(" (e to expand)"))
+		}
+		doc.WriteString("\n")
+	}
+	if (!collapsed) {
+		content := m.formatter.Render(post.content)
+		if (highlighted) {
+			doc.WriteString(m.headerStyle.Render(content))
+		} else {
+			doc.WriteString(content)
+		}
+		doc.WriteString("\n")
+		if post.poll != nil {
+			doc.WriteString(m.pollView(*post.poll))
+		}
 	}
-	doc.WriteString("\n")
 	if (post.liked) {
 		doc.WriteString(m.quitStyle.Render("❤ "))
 	}
@@ -178,6 +193,115 @@ func (m TimelineModel) postView(post Post, index int) string {
 	return doc.String()
 }
 
+func (m TimelineModel) pollView(poll Poll) string {
+	barWidth := 20
+	doc := strings.Builder{}
+	for i, option := range poll.options {
+		percent := 0
+		if poll.votes > 0 {
+			percent = option.votes * 100 / poll.votes
+		}
+		filled := percent * barWidth / 100
+		doc.WriteString(m.quitStyle.Render(fmt.Sprintf("%d ", i+1)))
+		doc.WriteString(m.barStyle.Render(strings.Repeat("█", filled)))
+		doc.WriteString(m.quitStyle.Render(strings.Repeat("░", barWidth-filled)))
+		doc.WriteString(m.numberStyle.Render(fmt.Sprintf(" %3d%% ", percent)))
+		doc.WriteString(SanitizeLine(option.content))
+		if poll.choice.Valid && poll.choice.Int64 == option.id {
+			doc.WriteString(m.headerStyle.Render(" ✔"))
+		}
+		doc.WriteString("\n")
+	}
+	doc.WriteString(m.numberStyle.Render(strconv.Itoa(poll.votes)))
+	doc.WriteString(m.quitStyle.Render(" Votes · "))
+	if poll.Closed() {
+		doc.WriteString(m.quitStyle.Render("Final results"))
+	} else {
+		doc.WriteString(m.quitStyle.Render(RemainingTime(poll.endsAt) + " left"))
+	}
+	doc.WriteString("\n")
+	return doc.String()
+}
+
+func RemainingTime(t time.Time) string {
+	duration := time.Until(t)
+
+	switch {
+	case duration < time.Minute:
+		return fmt.Sprintf("%.0fs", duration.Seconds())
+	case duration < time.Hour:
+		return fmt.Sprintf("%.0fm", duration.Minutes())
+	case duration < 24*time.Hour:
+		return fmt.Sprintf("%.0fh", duration.Hours())
+	default:
+		return fmt.Sprintf("%.0fd", duration.Hours()/24)
+	}
+}
+
+// Votable returns the current post if the user can still vote
+// for the given option of its poll.
+func (m TimelineModel) Votable(option int) (Post, bool) {
+	if m.currentPost >= len(m.posts) {
+		return Post{}, false
+	}
+	post := m.posts[m.currentPost]
+	poll := post.poll
+	if poll == nil || option >= len(poll.options) || poll.choice.Valid || poll.Closed() {
+		return Post{}, false
+	}
+	return post, true
+}
+
+func (m *TimelineModel) ApplyVote(postId int64, option int) {
+	for _, post := range m.posts {
+		if post.id != postId || post.poll == nil {
+			continue
+		}
+		poll := post.poll
+		poll.options[option].votes += 1
+		poll.votes += 1
+		poll.choice = sql.NullInt64{Valid: true, Int64: poll.options[option].id}
+		return
+	}
+}
+
+func (m TimelineModel) Current() (Post, bool) {
+	if m.currentPost >= len(m.posts) {
+		return Post{}, false
+	}
+	return m.posts[m.currentPost], true
+}
+
+func (m *TimelineModel) ApplyLike(postId int64, liked bool) {
+	for i, post := range m.posts {
+		if post.id != postId || post.liked == liked {
+			continue
+		}
+		m.posts[i].liked = liked
+		if liked {
+			m.posts[i].likes = post.likes + 1
+		} else {
+			m.posts[i].likes = post.likes - 1
+		}
+	}
+}
+
+func (m TimelineModel) Expanded(post Post) bool {
+	return m.user.expandWarnings != m.toggled[post.id]
+}
+
+// ToggleWarning expands or collapses the content warning of the post at index.
+func (m *TimelineModel) ToggleWarning(index int) {
+	if index < 0 || index >= len(m.posts) {
+		return
+	}
+	post := m.posts[index]
+	if !post.contentWarning.Valid {
+		return
+	}
+	m.toggled[post.id] = !m.toggled[post.id]
+}
+
 func (m *TimelineModel) Push(post Post) {
 	m.posts = append([]Post{post}, m.posts...)
 }
@@ -0,0 +1,26 @@
+package main
+
+import (
+	"database/sql"
+	"testing"
+
+	"github.com/charmbracelet/lipgloss"
+)
+
+func TestToggleWarningFlipsUserDefault(t *testing.T) {
+	post := Post{id: 1, contentWarning: sql.NullString{String: "spoilers", Valid: true}}
+	for _, expandWarnings := range []bool{false, true} {
+		timeline := getTimeline(lipgloss.DefaultRenderer(), nil, []Post{post}, SavedUser{expandWarnings: expandWarnings})
+		if got := timeline.Expanded(post); got != expandWarnings {
+			t.Errorf("expandWarnings=%v: expanded before toggling = %v", expandWarnings, got)
+		}
+		timeline.ToggleWarning(0)
+		if got := timeline.Expanded(post); got == expandWarnings {
+			t.Errorf("expandWarnings=%v: expanded after toggling = %v", expandWarnings, got)
+		}
+		timeline.ToggleWarning(0)
+		if got := timeline.Expanded(post); got != expandWarnings {
+			t.Errorf("expandWarnings=%v: expanded after toggling back = %v", expandWarnings, got)
+		}
+	}
+}
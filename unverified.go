@@ -32,6 +32,8 @@ func (m UnverifiedModel) Init() tea.Cmd {
 
 func (m UnverifiedModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	switch msg := msg.(type) {
+	case DrainMsg:
+		return m, tea.Quit
 	case tea.KeyMsg:
 		switch msg.String() {
 		case "q", "ctrl+c":
@@ -45,7 +47,7 @@ func (m UnverifiedModel) View() string {
 	return m.txtStyle.Render("Waiting for verification!") + 
 		"\n" + 
 		"Hello, " + 
-		m.userStyle.Render(m.username) +
+		m.userStyle.Render(SanitizeLine(m.username)) +
 		". Your account is unverified." + 
 		"\n\n" + 
 		m.quitStyle.Render("Press 'q' to quit\n")
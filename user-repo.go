@@ -1,7 +1,9 @@
 package main
 
 import (
+	"context"
 	"database/sql"
+	"errors"
 	"fmt"
 	"time"
 
@@ -21,90 +23,296 @@ type SavedUser struct {
 	description     sql.NullString
 	location        sql.NullString
 	birthDate       time.Time
+	expandWarnings  bool
+	suspended       bool
+	certificate     bool
 }
 
-func SaveUser(db *sql.DB, publicKey string, username string, email string, birthDate time.Time) (int64, error) {
+func SaveUser(ctx context.Context, db *sql.DB, publicKey string, username string, email string, birthDate time.Time) (int64, error) {
+	if err := checkWritable(); err != nil {
+		return 0, err
+	}
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
 	log.Info("Saving user to db")
 	query := `INSERT INTO users (key, username, email, verified, administrator, birth_date)
-			  VALUES ($1, $2, $3, $4, $5, $6)`
-	result, err := db.Exec(query, publicKey, username, email, false, false, birthDate)
+		  VALUES ($1, $2, $3, $4, $5, $6)
+		  RETURNING id`
+	var id int64
+	err := db.QueryRowContext(ctx, query, publicKey, username, SanitizeLine(email), false, false, birthDate).Scan(&id)
 
 	if err != nil {
 		log.Errorf("failed to insert user: %v", err)
-		return 0, fmt.Errorf("failed to insert user: %v", err)
+		return 0, fmt.Errorf("failed to insert user: %w", mapDbError(err))
 	}
 
 	log.Info("Saved new user")
-	id, err := result.LastInsertId()
-	if err != nil {
-		return 0, fmt.Errorf("failed to retrieve last inserted ID: %v", err)
-	}
-
 	return id, nil
 }
 
-func AcceptUser(db *sql.DB, user SavedUser) error {
+func AcceptUser(ctx context.Context, db *sql.DB, user SavedUser) error {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
 	query := `UPDATE users SET verified = true WHERE id = $1`
 
-	result, err := db.Exec(query, user.id)
+	result, err := db.ExecContext(ctx, query, user.id)
 	if err != nil {
 		log.Errorf("failed to update user verification status: %v", err)
-		return fmt.Errorf("failed to update user verification status: %v", err)
+		return fmt.Errorf("failed to update user verification status: %w", mapDbError(err))
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		log.Errorf("failed to retrieve affected rows: %v", err)
+		return fmt.Errorf("failed to retrieve affected rows: %w", mapDbError(err))
+	}
+
+	if rowsAffected == 0 {
+		log.Errorf("no user found with username: %s", user.username)
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+// ProvisionAdministrator makes sure username exists as a verified
+// administrator logging in with key, and tells whether it was
+// created. An existing account is only promoted when its key
+// matches, so the config can't take over someone's account.
+func ProvisionAdministrator(ctx context.Context, db *sql.DB, username string, key string, email string) (bool, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+	query := `
+	INSERT INTO users (key, username, email, verified, administrator, birth_date)
+	VALUES ($1, $2, $3, true, true, CURRENT_TIMESTAMP)
+	ON CONFLICT (username) DO UPDATE SET verified = true, administrator = true, suspended = false
+	WHERE users.key = EXCLUDED.key
+	RETURNING xmax = 0`
+	var created bool
+	err := db.QueryRowContext(ctx, query, key, username, SanitizeLine(email)).Scan(&created)
+	if errors.Is(err, sql.ErrNoRows) {
+		log.Errorf("cannot provision administrator %s: registered with another key", username)
+		return false, fmt.Errorf("%s is registered with another key", username)
+	}
+	if err != nil {
+		log.Errorf("failed to provision administrator: %v", err)
+		return false, fmt.Errorf("failed to provision administrator: %w", mapDbError(err))
+	}
+	return created, nil
+}
+
+// SaveCertificateUser creates the verified account of someone
+// logging in with a certificate. Another login may create it
+// first, which isn't an error.
+func SaveCertificateUser(ctx context.Context, db *sql.DB, username string, key string) error {
+	if err := checkWritable(); err != nil {
+		return err
+	}
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+	log.Info("Creating user from certificate", "user", username)
+	query := `
+	INSERT INTO users (key, username, email, verified, administrator, birth_date, certificate)
+	VALUES ($1, $2, '', true, false, CURRENT_TIMESTAMP, true)
+	ON CONFLICT (username) DO NOTHING`
+	if _, err := db.ExecContext(ctx, query, key, username); err != nil {
+		log.Errorf("failed to insert user: %v", err)
+		return fmt.Errorf("failed to insert user: %w", mapDbError(err))
+	}
+	return nil
+}
+
+// SetAdministrator grants or revokes moderation rights.
+func SetAdministrator(ctx context.Context, db *sql.DB, user SavedUser, administrator bool) error {
+	log.Info("Changing administrator status", "user", user.username, "administrator", administrator)
+	return updateUser(ctx, db, user, `UPDATE users SET administrator = $2 WHERE id = $1`, administrator)
+}
+
+// SetSuspended locks a user out without deleting their posts.
+func SetSuspended(ctx context.Context, db *sql.DB, user SavedUser, suspended bool) error {
+	log.Info("Changing suspension", "user", user.username, "suspended", suspended)
+	return updateUser(ctx, db, user, `UPDATE users SET suspended = $2 WHERE id = $1`, suspended)
+}
+
+// UpdateUserKey replaces the public key a user logs in with.
+func UpdateUserKey(ctx context.Context, db *sql.DB, user SavedUser, key string) error {
+	log.Info("Changing public key", "user", user.username)
+	return updateUser(ctx, db, user, `UPDATE users SET key = $2 WHERE id = $1`, key)
+}
+
+func updateUser(ctx context.Context, db *sql.DB, user SavedUser, query string, value any) error {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+	result, err := db.ExecContext(ctx, query, user.id, value)
+	if err != nil {
+		log.Errorf("failed to update user: %v", err)
+		return fmt.Errorf("failed to update user: %w", mapDbError(err))
 	}
 
 	rowsAffected, err := result.RowsAffected()
 	if err != nil {
 		log.Errorf("failed to retrieve affected rows: %v", err)
-		return fmt.Errorf("failed to retrieve affected rows: %v", err)
+		return fmt.Errorf("failed to retrieve affected rows: %w", mapDbError(err))
 	}
 
 	if rowsAffected == 0 {
 		log.Errorf("no user found with username: %s", user.username)
-		return fmt.Errorf("no user found with username: %s", user.username)
+		return ErrNotFound
 	}
 
 	return nil
 }
 
-func DeleteUser(db *sql.DB, user SavedUser) error {
-	query := `DELETE FROM users WHERE id = $1`
-	result, err := db.Exec(query, user.id)
+type DeletionPolicy string
+
+const (
+	// anonymizePosts keeps posts of deleted accounts under
+	// the deletedUsername placeholder.
+	anonymizePosts DeletionPolicy = "anonymize"
+	// removePosts deletes posts together with the account.
+	removePosts    DeletionPolicy = "remove"
+)
+
+const deletedUsername = "[deleted]"
+
+type deletionStep struct {
+	name  string
+	query string
+}
+
+var deletionPolicy = anonymizePosts
+
+func SetDeletionPolicy(policy DeletionPolicy) {
+	deletionPolicy = policy
+}
+
+// CreateDeletedUser adds the account that keeps anonymized
+// posts. Its key isn't a valid public key, so nobody can log in.
+func CreateDeletedUser(db *sql.DB) {
+	query := `
+	INSERT INTO users (key, username, email, verified, administrator, birth_date)
+	VALUES ($1, $1, '', true, false, CURRENT_TIMESTAMP)
+	ON CONFLICT DO NOTHING`
+
+	_, err := db.Exec(query, deletedUsername)
+	if err != nil {
+		log.Fatalf("Failed to create placeholder for deleted users: %v", err)
+	}
+}
+
+// DeleteAccount removes a user with their likes, votes and follows,
+// fixing the counters those touched, and then anonymizes or removes
+// their posts according to deletionPolicy. Everything happens in
+// one transaction.
+func DeleteAccount(ctx context.Context, db *sql.DB, user SavedUser) error {
+	if err := checkWritable(); err != nil {
+		return err
+	}
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+	log.Info("Deleting account", "user", user.username, "policy", deletionPolicy)
+	if user.username == deletedUsername {
+		return ErrNotFound
+	}
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		log.Errorf("failed to start transaction: %v", err)
+		return fmt.Errorf("failed to start transaction: %w", mapDbError(err))
+	}
+	defer tx.Rollback()
+
+	steps := []deletionStep{
+		{"lock user", `SELECT id FROM users WHERE id = $1 FOR UPDATE`},
+		{"decrement likes", `
+		UPDATE posts SET likes = GREATEST(likes - 1, 0)
+		WHERE id IN (SELECT post_id FROM likes WHERE user_id = $1)`},
+		{"delete likes", `DELETE FROM likes WHERE user_id = $1`},
+		{"decrement votes", `
+		UPDATE poll_options SET votes = GREATEST(votes - 1, 0)
+		WHERE id IN (SELECT option_id FROM poll_votes WHERE user_id = $1)`},
+		{"delete votes", `DELETE FROM poll_votes WHERE user_id = $1`},
+		{"decrement followers", `
+		UPDATE users SET followers = GREATEST(followers - 1, 0)
+		WHERE id IN (SELECT followed_id FROM follows WHERE user_id = $1)`},
+		{"decrement followed", `
+		UPDATE users SET followed = GREATEST(followed - 1, 0)
+		WHERE id IN (SELECT user_id FROM follows WHERE followed_id = $1)`},
+		{"delete follows", `DELETE FROM follows WHERE user_id = $1 OR followed_id = $1`},
+	}
+	if deletionPolicy == removePosts {
+		steps = append(steps, []deletionStep{
+			{"decrement replies", `
+			UPDATE posts p SET replies = GREATEST(p.replies - r.count, 0)
+			FROM (
+				SELECT parent_id, COUNT(*) AS count FROM posts
+				WHERE user_id = $1 AND parent_id IS NOT NULL
+				GROUP BY parent_id
+			) r
+			WHERE p.id = r.parent_id AND p.user_id <> $1`},
+			{"detach replies", `
+			UPDATE posts SET parent_id = NULL
+			WHERE user_id <> $1
+			AND parent_id IN (SELECT id FROM posts WHERE user_id = $1)`},
+			{"delete posts", `DELETE FROM posts WHERE user_id = $1`},
+		}...)
+	} else {
+		steps = append(steps, deletionStep{"anonymize posts", `
+		UPDATE posts SET user_id = (SELECT id FROM users WHERE username = '` + deletedUsername + `')
+		WHERE user_id = $1`})
+	}
+
+	for _, step := range steps {
+		if _, err := tx.ExecContext(ctx, step.query, user.id); err != nil {
+			log.Errorf("failed to delete account (%s): %v", step.name, err)
+			return fmt.Errorf("failed to delete account (%s): %w", step.name, mapDbError(err))
+		}
+	}
+
+	result, err := tx.ExecContext(ctx, `DELETE FROM users WHERE id = $1`, user.id)
 	if err != nil {
 		log.Errorf("failed to delete user: %v", err)
-		return fmt.Errorf("failed to delete user: %v", err)
+		return fmt.Errorf("failed to delete user: %w", mapDbError(err))
 	}
 
 	rowsAffected, err := result.RowsAffected()
 	if err != nil {
 		log.Errorf("failed to retrieve affected rows: %v", err)
-		return fmt.Errorf("failed to retrieve affected rows: %v", err)
+		return fmt.Errorf("failed to retrieve affected rows: %w", mapDbError(err))
 	}
 
 	if rowsAffected == 0 {
 		log.Errorf("no user found with username: %s", user.username)
-		return fmt.Errorf("no user found with username: %s", user.username)
+		return ErrNotFound
 	}
 
+	if err = tx.Commit(); err != nil {
+		log.Errorf("failed to commit account deletion: %v", err)
+		return fmt.Errorf("failed to commit account deletion: %w", mapDbError(err))
+	}
+
+	log.Info("Deleted account", "user", user.username)
 	return nil
 }
 
-func GetUserByUsername(db *sql.DB, username string) (SavedUser, bool) {
+func GetUserByUsername(ctx context.Context, db *sql.DB, username string) (SavedUser, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
 	var user SavedUser
 	log.Debug("Fetching user from db")
-	query := `SELECT id, key, username, email, verified, administrator, followers, followed, created_at, description, location, birth_date FROM users WHERE username = $1`
-	err := db.QueryRow(query, username).
-		Scan(&user.id, &user.key, &user.username, &user.email, &user.verified, &user.administrator, &user.followers, &user.followed, &user.createdAt, &user.description, &user.location, &user.birthDate)
+	query := `SELECT id, key, username, email, verified, administrator, followers, followed, created_at, description, location, birth_date, expand_warnings, suspended, certificate FROM users WHERE username = $1`
+	err := db.QueryRowContext(ctx, query, username).
+		Scan(&user.id, &user.key, &user.username, &user.email, &user.verified, &user.administrator, &user.followers, &user.followed, &user.createdAt, &user.description, &user.location, &user.birthDate, &user.expandWarnings, &user.suspended, &user.certificate)
 
 	if err != nil {
 		if err == sql.ErrNoRows {
 			log.Debug("No user found")
-			return SavedUser{}, false
+			return SavedUser{}, ErrNotFound
 		}
 		log.Errorf("Error while fetching user: %s", err)
-		return SavedUser{}, false
+		return SavedUser{}, fmt.Errorf("Error while fetching user: %w", mapDbError(err))
 	}
 
-	return user, true
+	return user, nil
 }
 
 func CreateUserTable(db *sql.DB) {
@@ -121,8 +329,14 @@ func CreateUserTable(db *sql.DB) {
 		created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
 		description VARCHAR(100),
 		location VARCHAR(50),
-		birth_date TIMESTAMP WITH TIME ZONE NOT NULL
-	);`
+		birth_date TIMESTAMP WITH TIME ZONE NOT NULL,
+		expand_warnings BOOLEAN NOT NULL DEFAULT false,
+		suspended BOOLEAN NOT NULL DEFAULT false,
+		certificate BOOLEAN NOT NULL DEFAULT false
+	);
+	ALTER TABLE users ADD COLUMN IF NOT EXISTS expand_warnings BOOLEAN NOT NULL DEFAULT false;
+	ALTER TABLE users ADD COLUMN IF NOT EXISTS suspended BOOLEAN NOT NULL DEFAULT false;
+	ALTER TABLE users ADD COLUMN IF NOT EXISTS certificate BOOLEAN NOT NULL DEFAULT false;`
 
 	_, err := db.Exec(createTableSQL)
 	if err != nil {
@@ -132,56 +346,65 @@ func CreateUserTable(db *sql.DB) {
 	log.Info("Table 'users' created successfully!")
 }
 
-func findQuery(db *sql.DB, query string) ([]SavedUser, error) {
-	rows, err := db.Query(query)
+func findQuery(ctx context.Context, db *sql.DB, query string) ([]SavedUser, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+	rows, err := db.QueryContext(ctx, query)
 	if err != nil {
-		return nil, err
+		return nil, mapDbError(err)
 	}
 	defer rows.Close()
 
 	var users []SavedUser
 	for rows.Next() {
 		var user SavedUser
-		if err := rows.Scan(&user.id, &user.key, &user.username, &user.email, &user.verified, &user.administrator, &user.followers, &user.followed, &user.createdAt); err != nil {
-			return nil, err
+		if err := rows.Scan(&user.id, &user.key, &user.username, &user.email, &user.verified, &user.administrator, &user.followers, &user.followed, &user.createdAt, &user.suspended); err != nil {
+			return nil, mapDbError(err)
 		}
 		users = append(users, user)
 	}
 
 	if err := rows.Err(); err != nil {
-		return nil, err
+		return nil, mapDbError(err)
 	}
 
 	return users, nil
 }
 
-func GetAllUsers(db *sql.DB) ([]SavedUser, error) {
-	query := `SELECT id, key, username, email, verified, administrator, followers, followed, created_at FROM users`
-	return findQuery(db, query)
+func GetAllUsers(ctx context.Context, db *sql.DB) ([]SavedUser, error) {
+	query := `SELECT id, key, username, email, verified, administrator, followers, followed, created_at, suspended FROM users ORDER BY id`
+	return findQuery(ctx, db, query)
 }
 
-func GetUnverifiedUsers(db *sql.DB) ([]SavedUser, error) {
-	query := `SELECT id, key, username, email, verified, administrator, followers, followed, created_at FROM users WHERE verified = false`
-	return findQuery(db, query)
+func GetUnverifiedUsers(ctx context.Context, db *sql.DB) ([]SavedUser, error) {
+	query := `SELECT id, key, username, email, verified, administrator, followers, followed, created_at, suspended FROM users WHERE verified = false`
+	return findQuery(ctx, db, query)
 }
 
-func UpdateUserData(db *sql.DB, user SavedUser, description string, location string) error {
-	query := `UPDATE users SET description = $2, location = $3 WHERE id = $1`
+func UpdateUserData(ctx context.Context, db *sql.DB, user SavedUser, description string, location string, expandWarnings bool) error {
+	if err := checkWritable(); err != nil {
+		return err
+	}
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+	query := `UPDATE users SET description = $2, location = $3, expand_warnings = $4 WHERE id = $1`
 
-	_, err := db.Exec(query, user.id, description, location)
+	_, err := db.ExecContext(ctx, query, user.id, SanitizeLine(description), SanitizeLine(location), expandWarnings)
 	if err != nil {
 		log.Errorf("failed to update user info status: %v", err)
-		return fmt.Errorf("failed to update user info status: %v", err)
+		return fmt.Errorf("failed to update user info status: %w", mapDbError(err))
 	}
 
 	return nil
 }
 
-func SearchUsers(db *sql.DB, search string) ([]SavedUser, error) {
+func SearchUsers(ctx context.Context, db *sql.DB, search string) ([]SavedUser, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
 	query := `SELECT id, key, username, email, verified, administrator, followers, followed, created_at FROM users WHERE  username LIKE '%' || $1 || '%'`
-	rows, err := db.Query(query, search)
+	rows, err := db.QueryContext(ctx, query, search)
 	if err != nil {
-		return nil, err
+		return nil, mapDbError(err)
 	}
 	defer rows.Close()
 
@@ -189,13 +412,13 @@ func SearchUsers(db *sql.DB, search string) ([]SavedUser, error) {
 	for rows.Next() {
 		var user SavedUser
 		if err := rows.Scan(&user.id, &user.key, &user.username, &user.email, &user.verified, &user.administrator, &user.followers, &user.followed, &user.createdAt); err != nil {
-			return nil, err
+			return nil, mapDbError(err)
 		}
 		users = append(users, user)
 	}
 
 	if err := rows.Err(); err != nil {
-		return nil, err
+		return nil, mapDbError(err)
 	}
 
 	return users, nil
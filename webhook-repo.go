@@ -0,0 +1,270 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/charmbracelet/log"
+)
+
+type Webhook struct {
+	id         int64
+	url        string
+	event      WebhookEvent
+	secret     string
+	createdAt  time.Time
+}
+
+type DeliveryStatus string
+
+const (
+	deliveryPending   DeliveryStatus = "pending"
+	deliveryDelivered DeliveryStatus = "delivered"
+	deliveryFailed    DeliveryStatus = "failed"
+)
+
+type WebhookDelivery struct {
+	id            int64
+	webhookId     int64
+	url           string
+	secret        string
+	event         WebhookEvent
+	payload       []byte
+	status        DeliveryStatus
+	attempts      int
+	nextAttemptAt time.Time
+	statusCode    sql.NullInt64
+	lastError     sql.NullString
+	createdAt     time.Time
+}
+
+func CreateWebhookTable(db *sql.DB) {
+	createTableSQL := `
+	CREATE TABLE IF NOT EXISTS webhooks (
+		id SERIAL PRIMARY KEY,
+		url TEXT NOT NULL,
+		event VARCHAR(32) NOT NULL,
+		secret VARCHAR(64) NOT NULL,
+		created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP
+	);
+	CREATE INDEX IF NOT EXISTS webhooks_event ON webhooks (event);`
+
+	_, err := db.Exec(createTableSQL)
+	if err != nil {
+		log.Fatalf("Failed to create table: %v", err)
+	}
+
+	log.Info("Table 'webhooks' created successfully!")
+}
+
+func CreateWebhookDeliveryTable(db *sql.DB) {
+	createTableSQL := `
+	CREATE TABLE IF NOT EXISTS webhook_deliveries (
+		id SERIAL PRIMARY KEY,
+		webhook_id INTEGER NOT NULL REFERENCES webhooks(id) ON DELETE CASCADE,
+		event VARCHAR(32) NOT NULL,
+		payload TEXT NOT NULL,
+		status VARCHAR(16) NOT NULL DEFAULT 'pending',
+		attempts INTEGER NOT NULL DEFAULT 0,
+		next_attempt_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+		status_code INTEGER,
+		last_error TEXT,
+		created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP
+	);
+	CREATE INDEX IF NOT EXISTS webhook_deliveries_due
+		ON webhook_deliveries (next_attempt_at) WHERE status = 'pending';`
+
+	_, err := db.Exec(createTableSQL)
+	if err != nil {
+		log.Fatalf("Failed to create table: %v", err)
+	}
+
+	log.Info("Table 'webhook_deliveries' created successfully!")
+}
+
+func SaveWebhook(ctx context.Context, db *sql.DB, url string, event WebhookEvent, secret string) (int64, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+	log.Info("Saving webhook", "event", event, "url", url)
+	var id int64
+	query := `INSERT INTO webhooks (url, event, secret) VALUES ($1, $2, $3) RETURNING id`
+	err := db.QueryRowContext(ctx, query, url, string(event), secret).Scan(&id)
+	if err != nil {
+		log.Errorf("failed to insert webhook: %v", err)
+		return 0, fmt.Errorf("failed to insert webhook: %w", mapDbError(err))
+	}
+	return id, nil
+}
+
+func DeleteWebhook(ctx context.Context, db *sql.DB, id int64) error {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+	log.Info("Deleting webhook", "id", id)
+	result, err := db.ExecContext(ctx, `DELETE FROM webhooks WHERE id = $1`, id)
+	if err != nil {
+		log.Errorf("failed to delete webhook: %v", err)
+		return fmt.Errorf("failed to delete webhook: %w", mapDbError(err))
+	}
+	if n, err := result.RowsAffected(); err == nil && n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func FindWebhooks(ctx context.Context, db *sql.DB) ([]Webhook, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+	query := `SELECT id, url, event, secret, created_at FROM webhooks ORDER BY id`
+	rows, err := db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, mapDbError(err)
+	}
+	defer rows.Close()
+
+	var hooks []Webhook
+	for rows.Next() {
+		var hook Webhook
+		if err := rows.Scan(&hook.id, &hook.url, &hook.event, &hook.secret, &hook.createdAt); err != nil {
+			return nil, mapDbError(err)
+		}
+		hooks = append(hooks, hook)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, mapDbError(err)
+	}
+
+	return hooks, nil
+}
+
+// EnqueueDeliveries adds a pending delivery of payload for
+// every webhook subscribed to event.
+func EnqueueDeliveries(ctx context.Context, db *sql.DB, event WebhookEvent, payload []byte) error {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+	query := `
+	INSERT INTO webhook_deliveries (webhook_id, event, payload)
+	SELECT id, event, $2 FROM webhooks WHERE event = $1`
+	_, err := db.ExecContext(ctx, query, string(event), string(payload))
+	if err != nil {
+		log.Errorf("failed to enqueue deliveries: %v", err)
+		return fmt.Errorf("failed to enqueue deliveries: %w", mapDbError(err))
+	}
+	return nil
+}
+
+// ClaimDeliveries picks up to limit due deliveries and moves
+// their next attempt lease into the future, so a crash while
+// sending retries them later instead of losing them. Rows locked
+// by another instance are skipped.
+func ClaimDeliveries(ctx context.Context, db *sql.DB, limit int, lease time.Duration) ([]WebhookDelivery, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+	query := `
+	UPDATE webhook_deliveries d
+	SET attempts = d.attempts + 1,
+	    next_attempt_at = NOW() + make_interval(secs => $2)
+	FROM webhooks w
+	WHERE w.id = d.webhook_id
+	AND d.id IN (
+		SELECT id FROM webhook_deliveries
+		WHERE status = 'pending' AND next_attempt_at <= NOW()
+		ORDER BY next_attempt_at
+		LIMIT $1
+		FOR UPDATE SKIP LOCKED
+	)
+	RETURNING d.id, d.webhook_id, w.url, w.secret, d.event, d.payload, d.attempts, d.created_at`
+	rows, err := db.QueryContext(ctx, query, limit, lease.Seconds())
+	if err != nil {
+		log.Errorf("failed to claim deliveries: %v", err)
+		return nil, fmt.Errorf("failed to claim deliveries: %w", mapDbError(err))
+	}
+	defer rows.Close()
+
+	var deliveries []WebhookDelivery
+	for rows.Next() {
+		var d WebhookDelivery
+		var payload string
+		if err := rows.Scan(&d.id, &d.webhookId, &d.url, &d.secret, &d.event, &payload, &d.attempts, &d.createdAt); err != nil {
+			return nil, mapDbError(err)
+		}
+		d.payload = []byte(payload)
+		d.status = deliveryPending
+		deliveries = append(deliveries, d)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, mapDbError(err)
+	}
+
+	return deliveries, nil
+}
+
+// FinishDelivery records the outcome of an attempt. A pending
+// status with retryAt schedules another attempt.
+func FinishDelivery(ctx context.Context, db *sql.DB, id int64, status DeliveryStatus, statusCode int, deliveryErr string, retryAt time.Time) error {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+	query := `
+	UPDATE webhook_deliveries
+	SET status = $2, status_code = NULLIF($3, 0), last_error = NULLIF($4, ''), next_attempt_at = $5
+	WHERE id = $1`
+	_, err := db.ExecContext(ctx, query, id, string(status), statusCode, deliveryErr, retryAt)
+	if err != nil {
+		log.Errorf("failed to update delivery: %v", err)
+		return fmt.Errorf("failed to update delivery: %w", mapDbError(err))
+	}
+	return nil
+}
+
+// RetryDelivery puts a failed delivery back in the queue.
+func RetryDelivery(ctx context.Context, db *sql.DB, id int64) error {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+	query := `
+	UPDATE webhook_deliveries
+	SET status = 'pending', attempts = 0, next_attempt_at = NOW()
+	WHERE id = $1 AND status = 'failed'`
+	result, err := db.ExecContext(ctx, query, id)
+	if err != nil {
+		log.Errorf("failed to retry delivery: %v", err)
+		return fmt.Errorf("failed to retry delivery: %w", mapDbError(err))
+	}
+	if n, err := result.RowsAffected(); err == nil && n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func FindRecentDeliveries(ctx context.Context, db *sql.DB, limit int) ([]WebhookDelivery, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+	query := `
+	SELECT d.id, d.webhook_id, w.url, d.event, d.status, d.attempts, d.next_attempt_at, d.status_code, d.last_error, d.created_at
+	FROM webhook_deliveries d
+	INNER JOIN webhooks w ON w.id = d.webhook_id
+	ORDER BY d.id DESC
+	LIMIT $1`
+	rows, err := db.QueryContext(ctx, query, limit)
+	if err != nil {
+		return nil, mapDbError(err)
+	}
+	defer rows.Close()
+
+	var deliveries []WebhookDelivery
+	for rows.Next() {
+		var d WebhookDelivery
+		if err := rows.Scan(&d.id, &d.webhookId, &d.url, &d.event, &d.status, &d.attempts, &d.nextAttemptAt, &d.statusCode, &d.lastError, &d.createdAt); err != nil {
+			return nil, mapDbError(err)
+		}
+		deliveries = append(deliveries, d)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, mapDbError(err)
+	}
+
+	return deliveries, nil
+}
@@ -0,0 +1,277 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/charmbracelet/log"
+)
+
+type WebhookEvent string
+
+const (
+	postCreatedEvent    WebhookEvent = "post.created"
+	replyCreatedEvent   WebhookEvent = "reply.created"
+	followCreatedEvent  WebhookEvent = "follow.created"
+	userRegisteredEvent WebhookEvent = "user.registered"
+	reportFiledEvent    WebhookEvent = "report.filed"
+)
+
+var webhookEvents = []WebhookEvent{
+	postCreatedEvent,
+	replyCreatedEvent,
+	followCreatedEvent,
+	userRegisteredEvent,
+	reportFiledEvent,
+}
+
+const (
+	signatureHeader = "X-Sshwitter-Signature"
+	eventHeader     = "X-Sshwitter-Event"
+	deliveryHeader  = "X-Sshwitter-Delivery"
+	webhookBatch    = 20
+	maxBackoff      = 6 * time.Hour
+)
+
+type webhookPayload struct {
+	Event      WebhookEvent `json:"event"`
+	OccurredAt time.Time    `json:"occurred_at"`
+	Data       any          `json:"data"`
+}
+
+type followPayload struct {
+	Follower string `json:"follower"`
+	Followed string `json:"followed"`
+}
+
+type registrationPayload struct {
+	Username string `json:"username"`
+}
+
+type reportPayload struct {
+	Id       int64   `json:"id"`
+	Reporter string  `json:"reporter"`
+	Post     apiPost `json:"post"`
+}
+
+// WebhookDispatcher sends queued deliveries. The queue lives in
+// webhook_deliveries, so events survive restarts and several
+// instances can share it.
+type WebhookDispatcher struct {
+	db          *sql.DB
+	client      *http.Client
+	interval    time.Duration
+	maxAttempts int
+	cancel      context.CancelFunc
+	done        chan struct{}
+}
+
+var webhooks *WebhookDispatcher
+
+func StartWebhookDispatcher(db *sql.DB, client *http.Client, interval time.Duration, maxAttempts int) *WebhookDispatcher {
+	ctx, cancel := context.WithCancel(context.Background())
+	dispatcher := &WebhookDispatcher{
+		db: db,
+		client: client,
+		interval: interval,
+		maxAttempts: maxAttempts,
+		cancel: cancel,
+		done: make(chan struct{}),
+	}
+	go dispatcher.run(ctx)
+	webhooks = dispatcher
+	return dispatcher
+}
+
+func (d *WebhookDispatcher) run(ctx context.Context) {
+	defer close(d.done)
+	ticker := time.NewTicker(d.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			d.deliverDue(ctx)
+		}
+	}
+}
+
+// Stop aborts the attempt in progress, which is retried later
+// like any other failure.
+func (d *WebhookDispatcher) Stop() {
+	d.cancel()
+	<-d.done
+}
+
+// deliverDue claims due deliveries one at a time, so each lease
+// only has to cover a single request and nothing sits claimed
+// behind a slow endpoint.
+func (d *WebhookDispatcher) deliverDue(ctx context.Context) {
+	lease := d.client.Timeout + time.Minute
+	for i := 0; i < webhookBatch; i++ {
+		if ctx.Err() != nil {
+			return
+		}
+		deliveries, err := ClaimDeliveries(ctx, d.db, 1, lease)
+		if err != nil {
+			log.Error("Could not read webhook queue", "error", err)
+			return
+		}
+		if len(deliveries) == 0 {
+			return
+		}
+		d.deliver(ctx, deliveries[0])
+	}
+}
+
+func (d *WebhookDispatcher) deliver(ctx context.Context, delivery WebhookDelivery) {
+	status, statusCode, message, retryAt := d.attempt(ctx, delivery, time.Now())
+	if err := FinishDelivery(context.WithoutCancel(ctx), d.db, delivery.id, status, statusCode, message, retryAt); err != nil {
+		log.Error("Could not record webhook delivery", "delivery", delivery.id, "error", err)
+	}
+}
+
+// attempt sends delivery and decides what becomes of it: delivered,
+// retried after a backoff from now, or failed for good.
+func (d *WebhookDispatcher) attempt(ctx context.Context, delivery WebhookDelivery, now time.Time) (DeliveryStatus, int, string, time.Time) {
+	statusCode, err := d.send(ctx, delivery)
+	if err == nil {
+		return deliveryDelivered, statusCode, "", now
+	}
+	if delivery.attempts >= d.maxAttempts {
+		log.Warn("Webhook delivery failed", "delivery", delivery.id, "url", delivery.url, "attempts", delivery.attempts, "error", err)
+		return deliveryFailed, statusCode, err.Error(), now
+	}
+	retryAt := now.Add(webhookBackoff(delivery.attempts))
+	log.Info("Webhook delivery will be retried", "delivery", delivery.id, "url", delivery.url, "at", retryAt, "error", err)
+	return deliveryPending, statusCode, err.Error(), retryAt
+}
+
+func (d *WebhookDispatcher) send(ctx context.Context, delivery WebhookDelivery) (int, error) {
+	request, err := http.NewRequestWithContext(ctx, http.MethodPost, delivery.url, bytes.NewReader(delivery.payload))
+	if err != nil {
+		return 0, err
+	}
+	request.Header.Set("Content-Type", "application/json")
+	request.Header.Set("User-Agent", "sshwitter-webhooks")
+	request.Header.Set(eventHeader, string(delivery.event))
+	request.Header.Set(deliveryHeader, strconv.FormatInt(delivery.id, 10))
+	request.Header.Set(signatureHeader, SignWebhook(delivery.secret, delivery.payload))
+	response, err := d.client.Do(request)
+	if err != nil {
+		return 0, err
+	}
+	defer response.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(response.Body, 64*1024))
+	if response.StatusCode < 200 || response.StatusCode > 299 {
+		return response.StatusCode, fmt.Errorf("unexpected status %s", response.Status)
+	}
+	return response.StatusCode, nil
+}
+
+// webhookBackoff doubles the wait after every failed attempt,
+// starting at 30 seconds.
+func webhookBackoff(attempts int) time.Duration {
+	backoff := 30 * time.Second
+	for i := 1; i < attempts && backoff < maxBackoff; i++ {
+		backoff *= 2
+	}
+	return min(backoff, maxBackoff)
+}
+
+// SignWebhook returns the signature header of body. Receivers
+// recompute the HMAC with their secret and compare.
+func SignWebhook(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+func VerifyWebhookSignature(secret string, body []byte, signature string) bool {
+	return hmac.Equal([]byte(SignWebhook(secret, body)), []byte(signature))
+}
+
+func NewWebhookSecret() (string, error) {
+	secret := make([]byte, 24)
+	if _, err := rand.Read(secret); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(secret), nil
+}
+
+func ValidWebhookEvent(event WebhookEvent) bool {
+	for _, e := range webhookEvents {
+		if e == event {
+			return true
+		}
+	}
+	return false
+}
+
+// notifyWebhooks queues event for every subscribed webhook. It
+// runs inside commands, so it outlives the tab that started it.
+func notifyWebhooks(ctx context.Context, event WebhookEvent, data any) {
+	if webhooks == nil {
+		return
+	}
+	payload, err := json.Marshal(webhookPayload{Event: event, OccurredAt: time.Now().UTC(), Data: data})
+	if err != nil {
+		log.Error("Could not encode webhook payload", "event", event, "error", err)
+		return
+	}
+	if err := EnqueueDeliveries(context.WithoutCancel(ctx), webhooks.db, event, payload); err != nil {
+		log.Error("Could not queue webhook event", "event", event, "error", err)
+	}
+}
+
+func notifyPost(ctx context.Context, event WebhookEvent, postId int64) {
+	if webhooks == nil {
+		return
+	}
+	post, err := GetPostById(context.WithoutCancel(ctx), webhooks.db, postId, SavedUser{})
+	if err != nil {
+		log.Error("Could not load post for webhook", "post", postId, "error", err)
+		return
+	}
+	notifyWebhooks(ctx, event, toApiPost(post))
+}
+
+func NotifyPostCreated(ctx context.Context, postId int64) {
+	notifyPost(ctx, postCreatedEvent, postId)
+}
+
+func NotifyReplyCreated(ctx context.Context, postId int64) {
+	notifyPost(ctx, replyCreatedEvent, postId)
+}
+
+func NotifyFollowCreated(ctx context.Context, user SavedUser, followed SavedUser) {
+	notifyWebhooks(ctx, followCreatedEvent, followPayload{Follower: user.username, Followed: followed.username})
+}
+
+func NotifyUserRegistered(ctx context.Context, username string) {
+	notifyWebhooks(ctx, userRegisteredEvent, registrationPayload{Username: username})
+}
+
+func NotifyReportFiled(ctx context.Context, id int64, reporter SavedUser, postId int64) {
+	if webhooks == nil {
+		return
+	}
+	post, err := GetPostById(context.WithoutCancel(ctx), webhooks.db, postId, SavedUser{})
+	if err != nil {
+		log.Error("Could not load post for webhook", "post", postId, "error", err)
+		return
+	}
+	notifyWebhooks(ctx, reportFiledEvent, reportPayload{Id: id, Reporter: reporter.username, Post: toApiPost(post)})
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func testDelivery(url string, attempts int) WebhookDelivery {
+	return WebhookDelivery{
+		id:       7,
+		url:      url,
+		secret:   "s3cret",
+		event:    "post.created",
+		payload:  []byte(`{"post":1}`),
+		attempts: attempts,
+	}
+}
+
+func TestWebhookSendSignsPayload(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			t.Error(err)
+		}
+		if !VerifyWebhookSignature("s3cret", body, r.Header.Get(signatureHeader)) {
+			t.Errorf("signature %q does not match body %q", r.Header.Get(signatureHeader), body)
+		}
+		if got := r.Header.Get(eventHeader); got != "post.created" {
+			t.Errorf("event header = %q, want post.created", got)
+		}
+		if got := r.Header.Get(deliveryHeader); got != "7" {
+			t.Errorf("delivery header = %q, want 7", got)
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	dispatcher := &WebhookDispatcher{client: &http.Client{Timeout: time.Second}, maxAttempts: 3}
+	now := time.Now()
+	status, code, message, retryAt := dispatcher.attempt(context.Background(), testDelivery(server.URL, 1), now)
+	if status != deliveryDelivered || code != http.StatusNoContent || message != "" || !retryAt.Equal(now) {
+		t.Errorf("attempt() = %v, %d, %q, %v; want delivered, 204", status, code, message, retryAt)
+	}
+}
+
+func TestWebhookAttemptSchedulesRetries(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer server.Close()
+
+	dispatcher := &WebhookDispatcher{client: &http.Client{Timeout: time.Second}, maxAttempts: 3}
+	now := time.Now()
+	tests := []struct {
+		attempts int
+		status   DeliveryStatus
+		retryAt  time.Time
+	}{
+		{1, deliveryPending, now.Add(30 * time.Second)},
+		{2, deliveryPending, now.Add(time.Minute)},
+		{3, deliveryFailed, now},
+	}
+	for _, test := range tests {
+		status, code, message, retryAt := dispatcher.attempt(context.Background(), testDelivery(server.URL, test.attempts), now)
+		if status != test.status || !retryAt.Equal(test.retryAt) {
+			t.Errorf("attempt %d: got %v at %v, want %v at %v", test.attempts, status, retryAt, test.status, test.retryAt)
+		}
+		if code != http.StatusInternalServerError || message == "" {
+			t.Errorf("attempt %d: got code %d, message %q", test.attempts, code, message)
+		}
+	}
+}
+
+func TestWebhookBackoff(t *testing.T) {
+	tests := []struct {
+		attempts int
+		want     time.Duration
+	}{
+		{1, 30 * time.Second},
+		{2, time.Minute},
+		{3, 2 * time.Minute},
+		{10, 256 * time.Minute},
+		{11, maxBackoff},
+		{100, maxBackoff},
+	}
+	for _, test := range tests {
+		if got := webhookBackoff(test.attempts); got != test.want {
+			t.Errorf("webhookBackoff(%d) = %v, want %v", test.attempts, got, test.want)
+		}
+	}
+}